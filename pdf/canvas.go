@@ -3,7 +3,7 @@
 package pdf
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"io"
@@ -34,11 +34,77 @@ func writeCommand(w io.Writer, op string, args ...interface{}) error {
 // Canvas is a two-dimensional drawing region on a single page.  You can obtain
 // a canvas once you have created a document.
 type Canvas struct {
-	doc          *Document
-	page         *pageDict
-	ref          Reference
-	contents     *stream
-	imageCounter uint
+	doc       *Document
+	page      *pageDict
+	ref       Reference
+	contents  *stream
+	resources *resources
+	mediaBox  *Rectangle
+	cropBox   *Rectangle
+
+	// err is the first error encountered while drawing.
+	err error
+
+	// markedContent is the number of open marked content sequences.
+	markedContent int
+
+	// state mirrors the graphics state of the content stream, and stack
+	// holds the states saved by Push.
+	state graphicsState
+	stack []graphicsState
+}
+
+// graphicsState holds the graphics state parameters tracked by a canvas.
+// Operators that would not change a known parameter are not written.
+type graphicsState struct {
+	// ctm is the current transformation matrix, relative to the canvas's
+	// default coordinate system.
+	ctm         Matrix
+	lineWidth   Unit
+	lineCap     LineCap
+	lineJoin    LineJoin
+	miterLimit  float32
+	dashPhase   Unit
+	dash        []Unit
+	fillColor   RGB
+	strokeColor RGB
+	font        name
+	fontSize    Unit
+	leading     Unit
+
+	// known records which of the parameters are known to be in effect.  A
+	// form XObject inherits the graphics state of whatever draws it, so its
+	// canvas starts with nothing known.  Patterns unset the colors.  The
+	// text state is known once a text object has been drawn.
+	known stateFlags
+}
+
+type stateFlags uint
+
+const (
+	knownLineWidth stateFlags = 1 << iota
+	knownLineDash
+	knownFillColor
+	knownStrokeColor
+	knownFont
+	knownLeading
+	knownLineCap
+	knownLineJoin
+	knownMiterLimit
+
+	knownAll = knownLineWidth | knownLineDash | knownFillColor | knownStrokeColor |
+		knownLineCap | knownLineJoin | knownMiterLimit
+)
+
+// newGraphicsState returns the initial graphics state of a canvas.  The
+// initial parameters are only known to be in effect for pages.
+func newGraphicsState(known stateFlags) graphicsState {
+	return graphicsState{
+		ctm:        IdentityMatrix,
+		lineWidth:  1,
+		miterLimit: 10,
+		known:      known,
+	}
 }
 
 // Document returns the document the canvas is attached to.
@@ -46,56 +112,211 @@ func (canvas *Canvas) Document() *Document {
 	return canvas.doc
 }
 
+// Reference returns the reference of the object the canvas draws into: the
+// page for canvases returned by NewPage, or the XObject for canvases returned
+// by methods like NewGroup.
+func (canvas *Canvas) Reference() Reference {
+	return canvas.ref
+}
+
 // Close flushes the page's stream to the document.  This must be called once
-// drawing has completed or else the document will be inconsistent.
+// drawing has completed or else the document will be inconsistent.  Close
+// returns the first error encountered while drawing, including a Push without
+// a matching Pop.
 func (canvas *Canvas) Close() error {
-	return canvas.contents.Close()
+	canvas.checkBalance()
+	if err := canvas.contents.Close(); err != nil {
+		canvas.setErr(err)
+	}
+	return canvas.err
+}
+
+// Err returns the first error encountered while drawing on the canvas.  Once
+// an error has occurred, all further drawing operations are ignored.  The
+// error is also returned by Close and by the document's Encode method.
+func (canvas *Canvas) Err() error {
+	return canvas.err
+}
+
+// checkBalance records an error if the canvas has graphics states or marked
+// content sequences that have not been closed.
+func (canvas *Canvas) checkBalance() {
+	if n := len(canvas.stack); n > 0 {
+		canvas.setErr(fmt.Errorf("pdf: %d Push calls without matching Pop", n))
+	}
+	if n := canvas.markedContent; n > 0 {
+		canvas.setErr(fmt.Errorf("pdf: %d marked content sequences not ended", n))
+	}
 }
 
-// Size returns the page's media box (the size of the physical medium).
+// setErr records err unless an earlier error has already been recorded.
+func (canvas *Canvas) setErr(err error) {
+	if canvas.err == nil {
+		canvas.err = err
+	}
+}
+
+// command writes a graphics command to the canvas's content stream.
+func (canvas *Canvas) command(op string, args ...interface{}) {
+	if canvas.err != nil {
+		return
+	}
+	canvas.setErr(writeCommand(canvas.contents, op, args...))
+}
+
+// Size returns the page's media box (the size of the physical medium).  For
+// canvases that do not draw onto a page, this is the size of the bounding box.
 func (canvas *Canvas) Size() (width, height Unit) {
-	mbox := canvas.page.MediaBox
+	mbox := *canvas.mediaBox
 	return mbox.Dx(), mbox.Dy()
 }
 
 // SetSize changes the page's media box (the size of the physical medium).
 func (canvas *Canvas) SetSize(width, height Unit) {
-	canvas.page.MediaBox = Rectangle{Point{0, 0}, Point{width, height}}
+	*canvas.mediaBox = Rectangle{Point{0, 0}, Point{width, height}}
 }
 
-// CropBox returns the page's crop box.
+// CropBox returns the page's crop box.  For canvases that do not draw onto a
+// page, this is the bounding box.
 func (canvas *Canvas) CropBox() Rectangle {
-	return canvas.page.CropBox
+	return *canvas.cropBox
 }
 
 // SetCropBox changes the page's crop box.
 func (canvas *Canvas) SetCropBox(crop Rectangle) {
-	canvas.page.CropBox = crop
+	*canvas.cropBox = crop
+}
+
+// write writes raw data to the canvas's content stream.
+func (canvas *Canvas) write(p []byte) {
+	if canvas.err != nil {
+		return
+	}
+	_, err := canvas.contents.Write(p)
+	canvas.setErr(err)
+}
+
+// paintPath appends the given path to the content stream followed by a path
+// painting operator.
+func (canvas *Canvas) paintPath(p *Path, op string) {
+	if canvas.err != nil {
+		return
+	}
+	canvas.setErr(p.writeTo(canvas.contents))
+	canvas.command(op)
 }
 
 // FillStroke fills then strokes the given path.  This operation has the same
 // effect as performing a fill then a stroke, but does not repeat the path in
 // the file.
 func (canvas *Canvas) FillStroke(p *Path) {
-	io.Copy(canvas.contents, &p.buf)
-	writeCommand(canvas.contents, "B")
+	canvas.paintPath(p, "B")
 }
 
 // Fill paints the area enclosed by the given path using the current fill color.
 func (canvas *Canvas) Fill(p *Path) {
-	io.Copy(canvas.contents, &p.buf)
-	writeCommand(canvas.contents, "f")
+	canvas.paintPath(p, "f")
 }
 
 // Stroke paints a line along the given path using the current stroke color.
 func (canvas *Canvas) Stroke(p *Path) {
-	io.Copy(canvas.contents, &p.buf)
-	writeCommand(canvas.contents, "S")
+	canvas.paintPath(p, "S")
+}
+
+// Clip intersects the current clipping path with the area enclosed by the
+// given path.  The clipping path is part of the graphics state, so it can be
+// undone by surrounding Clip with Push and Pop.
+func (canvas *Canvas) Clip(p *Path) {
+	canvas.paintPath(p, "W n")
 }
 
 // SetLineWidth changes the stroke width to the given value.
 func (canvas *Canvas) SetLineWidth(w Unit) {
-	writeCommand(canvas.contents, "w", w)
+	if canvas.state.known&knownLineWidth != 0 && canvas.state.lineWidth == w {
+		return
+	}
+	canvas.command("w", w)
+	canvas.state.lineWidth = w
+	canvas.state.known |= knownLineWidth
+}
+
+// LineWidth returns the current stroke width.
+func (canvas *Canvas) LineWidth() Unit {
+	return canvas.state.lineWidth
+}
+
+// A LineCap is the shape of the ends of stroked open subpaths.
+type LineCap int
+
+// Line caps
+const (
+	// ButtCap squares off lines at their ends.
+	ButtCap LineCap = iota
+	// RoundCap ends lines with semicircles.
+	RoundCap
+	// SquareCap extends lines by half the line width beyond their ends.
+	SquareCap
+)
+
+// SetLineCap changes the shape of the ends of stroked lines.
+func (canvas *Canvas) SetLineCap(c LineCap) {
+	if canvas.state.known&knownLineCap != 0 && canvas.state.lineCap == c {
+		return
+	}
+	canvas.command("J", int(c))
+	canvas.state.lineCap = c
+	canvas.state.known |= knownLineCap
+}
+
+// LineCap returns the current line cap style.
+func (canvas *Canvas) LineCap() LineCap {
+	return canvas.state.lineCap
+}
+
+// A LineJoin is the shape of the corners of stroked paths.
+type LineJoin int
+
+// Line joins
+const (
+	// MiterJoin extends the outer edges of the lines until they meet, as
+	// limited by the miter limit.
+	MiterJoin LineJoin = iota
+	// RoundJoin rounds corners with a circular arc.
+	RoundJoin
+	// BevelJoin cuts corners off.
+	BevelJoin
+)
+
+// SetLineJoin changes the shape of the corners of stroked paths.
+func (canvas *Canvas) SetLineJoin(j LineJoin) {
+	if canvas.state.known&knownLineJoin != 0 && canvas.state.lineJoin == j {
+		return
+	}
+	canvas.command("j", int(j))
+	canvas.state.lineJoin = j
+	canvas.state.known |= knownLineJoin
+}
+
+// LineJoin returns the current line join style.
+func (canvas *Canvas) LineJoin() LineJoin {
+	return canvas.state.lineJoin
+}
+
+// SetMiterLimit changes the miter limit, the largest ratio of the length of a
+// mitered corner to the line width before the corner is beveled instead.
+// The initial miter limit is 10.
+func (canvas *Canvas) SetMiterLimit(limit float32) {
+	if canvas.state.known&knownMiterLimit != 0 && canvas.state.miterLimit == limit {
+		return
+	}
+	canvas.command("M", limit)
+	canvas.state.miterLimit = limit
+	canvas.state.known |= knownMiterLimit
+}
+
+// MiterLimit returns the current miter limit.
+func (canvas *Canvas) MiterLimit() float32 {
+	return canvas.state.miterLimit
 }
 
 // SetLineDash changes the line dash pattern in the current graphics state.
@@ -106,47 +327,128 @@ func (canvas *Canvas) SetLineWidth(w Unit) {
 //   c.SetLineDash(0, []Unit{2, 1}) // 2 units on, 1 unit off...
 //   c.SetLineDash(1, []Unit{2})    // 1 unit on, 2 units off, 2 units on...
 func (canvas *Canvas) SetLineDash(phase Unit, dash []Unit) {
-	writeCommand(canvas.contents, "d", dash, phase)
+	if canvas.state.known&knownLineDash != 0 && canvas.state.dashPhase == phase && equalDash(canvas.state.dash, dash) {
+		return
+	}
+	canvas.command("d", dash, phase)
+	canvas.state.dashPhase = phase
+	canvas.state.dash = append([]Unit(nil), dash...)
+	canvas.state.known |= knownLineDash
+}
+
+func equalDash(a, b []Unit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LineDash returns the current line dash pattern.
+func (canvas *Canvas) LineDash() (phase Unit, dash []Unit) {
+	return canvas.state.dashPhase, append([]Unit(nil), canvas.state.dash...)
 }
 
 // SetColor changes the current fill color to the given RGB triple (in device
 // RGB space).
 func (canvas *Canvas) SetColor(r, g, b float32) {
-	writeCommand(canvas.contents, "rg", r, g, b)
+	c := RGB{r, g, b}
+	if canvas.state.known&knownFillColor != 0 && canvas.state.fillColor == c {
+		return
+	}
+	canvas.command("rg", r, g, b)
+	canvas.state.fillColor = c
+	canvas.state.known |= knownFillColor
+}
+
+// FillColor returns the most recently set RGB fill color.  The initial fill
+// color is black.
+func (canvas *Canvas) FillColor() (r, g, b float32) {
+	c := canvas.state.fillColor
+	return c.R, c.G, c.B
 }
 
 // SetStrokeColor changes the current stroke color to the given RGB triple (in
 // device RGB space).
 func (canvas *Canvas) SetStrokeColor(r, g, b float32) {
-	writeCommand(canvas.contents, "RG", r, g, b)
+	c := RGB{r, g, b}
+	if canvas.state.known&knownStrokeColor != 0 && canvas.state.strokeColor == c {
+		return
+	}
+	canvas.command("RG", r, g, b)
+	canvas.state.strokeColor = c
+	canvas.state.known |= knownStrokeColor
+}
+
+// StrokeColor returns the most recently set RGB stroke color.  The initial
+// stroke color is black.
+func (canvas *Canvas) StrokeColor() (r, g, b float32) {
+	c := canvas.state.strokeColor
+	return c.R, c.G, c.B
 }
 
 // Push saves a copy of the current graphics state.  The state can later be
 // restored using Pop.
 func (canvas *Canvas) Push() {
-	writeCommand(canvas.contents, "q")
+	canvas.command("q")
+	state := canvas.state
+	state.dash = append([]Unit(nil), state.dash...)
+	canvas.stack = append(canvas.stack, state)
 }
 
 // Pop restores the most recently saved graphics state by popping it from the
 // stack.
 func (canvas *Canvas) Pop() {
-	writeCommand(canvas.contents, "Q")
+	n := len(canvas.stack)
+	if n == 0 {
+		canvas.setErr(errors.New("pdf: Pop without matching Push"))
+		return
+	}
+	canvas.command("Q")
+	canvas.state = canvas.stack[n-1]
+	canvas.stack = canvas.stack[:n-1]
+}
+
+// Matrix returns the current transformation matrix, which maps the canvas's
+// current coordinate system to its default coordinate system.  For a page,
+// the default coordinate system is the page's default user space.
+func (canvas *Canvas) Matrix() Matrix {
+	return canvas.state.ctm
+}
+
+// ToPage converts a point in the canvas's current coordinate system to its
+// default coordinate system.
+func (canvas *Canvas) ToPage(pt Point) Point {
+	return canvas.state.ctm.Apply(pt)
+}
+
+// concat records the concatenation of m with the current transformation
+// matrix.
+func (canvas *Canvas) concat(m Matrix) {
+	canvas.state.ctm = m.Multiply(canvas.state.ctm)
 }
 
 // Translate moves the canvas's coordinates system by the given offset.
 func (canvas *Canvas) Translate(x, y Unit) {
-	writeCommand(canvas.contents, "cm", 1, 0, 0, 1, x, y)
+	canvas.command("cm", 1, 0, 0, 1, x, y)
+	canvas.concat(Matrix{1, 0, 0, 1, float32(x), float32(y)})
 }
 
 // Rotate rotates the canvas's coordinate system by a given angle (in radians).
 func (canvas *Canvas) Rotate(theta float32) {
 	s, c := math.Sin(float64(theta)), math.Cos(float64(theta))
-	writeCommand(canvas.contents, "cm", c, s, -s, c, 0, 0)
+	canvas.command("cm", c, s, -s, c, 0, 0)
+	canvas.concat(Matrix{float32(c), float32(s), float32(-s), float32(c), 0, 0})
 }
 
 // Scale multiplies the canvas's coordinate system by the given scalars.
 func (canvas *Canvas) Scale(x, y float32) {
-	writeCommand(canvas.contents, "cm", x, 0, 0, y, 0, 0)
+	canvas.command("cm", x, 0, 0, y, 0, 0)
+	canvas.concat(Matrix{x, 0, 0, y, 0, 0})
 }
 
 // Transform concatenates a 3x3 matrix with the current transformation matrix.
@@ -158,11 +460,16 @@ func (canvas *Canvas) Scale(x, y float32) {
 //
 // For more information, see Section 8.3.4 of ISO 32000-1.
 func (canvas *Canvas) Transform(a, b, c, d, e, f float32) {
-	writeCommand(canvas.contents, "cm", a, b, c, d, e, f)
+	canvas.command("cm", a, b, c, d, e, f)
+	canvas.concat(Matrix{a, b, c, d, e, f})
 }
 
 // DrawText paints a text object onto the canvas.
 func (canvas *Canvas) DrawText(text *Text) {
+	if text.err != nil {
+		canvas.setErr(text.err)
+		return
+	}
 	for _, font := range text.fonts {
 		if f, ok := canvas.doc.fonts[font.pdfName]; ok {
 			font = f
@@ -178,31 +485,51 @@ func (canvas *Canvas) DrawText(text *Text) {
 			})
 			canvas.doc.fonts[font.pdfName] = font
 		}
-		if _, ok := canvas.page.Resources.Font[font.pdfName]; !ok {
-			canvas.page.Resources.Font[font.pdfName] = font.pdfDict
+		if _, ok := canvas.resources.Font[font.pdfName]; !ok {
+			canvas.resources.Font[font.pdfName] = font.pdfDict
 		}
 	}
-	writeCommand(canvas.contents, "BT")
-	io.Copy(canvas.contents, &text.buf)
-	writeCommand(canvas.contents, "ET")
+	state := &canvas.state
+	canvas.command("BT")
+	if f := text.startFont; f != nil && (state.known&knownFont == 0 || state.font != f.pdfName || state.fontSize != text.startSize) {
+		canvas.command("Tf", f.pdfName, text.startSize)
+	}
+	if text.startHasLeading && (state.known&knownLeading == 0 || state.leading != text.startLeading) {
+		canvas.command("TL", text.startLeading)
+	}
+	canvas.write(text.buf.Bytes()[text.prefix:])
+	canvas.command("ET")
+	if text.currFont != nil {
+		state.font, state.fontSize = text.currFont.pdfName, text.currSize
+		state.known |= knownFont
+	}
+	if text.hasLeading {
+		state.leading = text.currLeading
+		state.known |= knownLeading
+	}
 }
 
 // DrawImage paints a raster image at the given location and scaled to the
-// given dimensions.  If you want to render the same image multiple times in
-// the same document, use DrawImageReference.
+// given dimensions.  Small opaque images are written inline into the content
+// stream, as if by DrawInlineImage.  Larger images are stored in the document
+// only once, no matter how often they are drawn; to avoid encoding the image
+// each time, add it with Document.AddImage and use DrawImageReference.
 func (canvas *Canvas) DrawImage(img image.Image, rect Rectangle) {
+	if isSmallImage(img) && isOpaque(img) {
+		canvas.DrawInlineImage(img, rect)
+		return
+	}
 	canvas.DrawImageReference(canvas.doc.AddImage(img), rect)
 }
 
 // DrawImageReference paints the raster image referenced in the document at the
 // given location and scaled to the given dimensions.
 func (canvas *Canvas) DrawImageReference(ref Reference, rect Rectangle) {
-	name := canvas.nextImageName()
-	canvas.page.Resources.XObject[name] = ref
+	name := resourceName(&canvas.resources.XObject, anonymousImageFormat, ref)
 
 	canvas.Push()
 	canvas.Transform(float32(rect.Dx()), 0, 0, float32(rect.Dy()), float32(rect.Min.X), float32(rect.Min.Y))
-	writeCommand(canvas.contents, "Do", name)
+	canvas.command("Do", name)
 	canvas.Pop()
 }
 
@@ -217,46 +544,24 @@ func (canvas *Canvas) DrawLine(pt1, pt2 Point) {
 
 const anonymousImageFormat = "__image%d__"
 
-func (canvas *Canvas) nextImageName() name {
-	var n name
-	for {
-		n = name(fmt.Sprintf(anonymousImageFormat, canvas.imageCounter))
-		canvas.imageCounter++
-		if _, ok := canvas.page.Resources.XObject[n]; !ok {
-			break
+// resourceName returns the name under which obj is registered in a resource
+// dictionary.  If obj is not in the dictionary yet, it is added under a new
+// name created from format.  The dictionary is created if it is nil.  obj must
+// be comparable.
+func resourceName(dict *map[name]interface{}, format string, obj interface{}) name {
+	if *dict == nil {
+		*dict = make(map[name]interface{})
+	}
+	for n, v := range *dict {
+		if v == obj {
+			return n
+		}
+	}
+	for i := len(*dict); ; i++ {
+		n := name(fmt.Sprintf(format, i))
+		if _, ok := (*dict)[n]; !ok {
+			(*dict)[n] = obj
+			return n
 		}
 	}
-	return n
-}
-
-// Path is a shape that can be painted on a canvas.  The zero value is an empty
-// path.
-type Path struct {
-	buf bytes.Buffer
-}
-
-// Move begins a new subpath by moving the current point to the given location.
-func (path *Path) Move(pt Point) {
-	writeCommand(&path.buf, "m", pt.X, pt.Y)
-}
-
-// Line appends a line segment from the current point to the given location.
-func (path *Path) Line(pt Point) {
-	writeCommand(&path.buf, "l", pt.X, pt.Y)
-}
-
-// Curve appends a cubic Bezier curve to the path.
-func (path *Path) Curve(pt1, pt2, pt3 Point) {
-	writeCommand(&path.buf, "c", pt1.X, pt1.Y, pt2.X, pt2.Y, pt3.X, pt3.Y)
-}
-
-// Rectangle appends a complete rectangle to the path.
-func (path *Path) Rectangle(rect Rectangle) {
-	writeCommand(&path.buf, "re", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
-}
-
-// Close appends a line segment from the current point to the starting point of
-// the subpath.
-func (path *Path) Close() {
-	writeCommand(&path.buf, "h")
 }
@@ -3,6 +3,7 @@
 package pdf
 
 import (
+	"io/ioutil"
 	"testing"
 )
 
@@ -19,7 +20,213 @@ func TestPath(t *testing.T) {
 	path.Close()
 	path.Rectangle(Rectangle{Point{3.1, -5.9}, Point{24.2, 75.0}})
 
-	if path.buf.String() != pathExpectedOutput {
-		t.Errorf("Output was %q, expected %q", path.buf.String(), pathExpectedOutput)
+	if pathString(path) != pathExpectedOutput {
+		t.Errorf("Output was %q, expected %q", pathString(path), pathExpectedOutput)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	m := Matrix{0, 1, -1, 0, 10, 20}
+	if pt := m.Apply(Point{1, 2}); pt != (Point{8, 21}) {
+		t.Errorf("Apply = %v, expected %v", pt, Point{8, 21})
+	}
+	inv, ok := m.Invert()
+	if !ok {
+		t.Fatal("Invert reported a singular matrix")
+	}
+	if p := m.Multiply(inv); p != IdentityMatrix {
+		t.Errorf("m.Multiply(inv) = %v, expected %v", p, IdentityMatrix)
+	}
+	if _, ok := (Matrix{1, 2, 2, 4, 0, 0}).Invert(); ok {
+		t.Error("Invert succeeded on a singular matrix")
+	}
+	// Multiply applies the receiver first.
+	scale, move := Matrix{2, 0, 0, 2, 0, 0}, Matrix{1, 0, 0, 1, 5, 0}
+	if pt := scale.Multiply(move).Apply(Point{1, 1}); pt != (Point{7, 2}) {
+		t.Errorf("scale.Multiply(move).Apply = %v, expected %v", pt, Point{7, 2})
+	}
+}
+
+func TestCanvasMatrix(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	if m := canvas.Matrix(); m != IdentityMatrix {
+		t.Errorf("initial Matrix() = %v, expected %v", m, IdentityMatrix)
+	}
+	canvas.Translate(100, 200)
+	canvas.Push()
+	canvas.Scale(2, 3)
+	if pt := canvas.ToPage(Point{10, 10}); pt != (Point{120, 230}) {
+		t.Errorf("ToPage = %v, expected %v", pt, Point{120, 230})
+	}
+	canvas.Pop()
+	if m := canvas.Matrix(); m != (Matrix{1, 0, 0, 1, 100, 200}) {
+		t.Errorf("Matrix() after Pop = %v, expected %v", m, Matrix{1, 0, 0, 1, 100, 200})
+	}
+}
+
+const graphicsStateExpectedOutput = `1.00000 0.00000 0.00000 rg
+2.00000 w
+q
+0.00000 0.00000 1.00000 rg
+Q
+0.00000 0.00000 1.00000 rg
+/Pattern cs
+/__pattern0__ scn
+0.00000 0.00000 1.00000 rg
+`
+
+func TestCanvasGraphicsState(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetColor(1, 0, 0)
+	canvas.SetColor(1, 0, 0)
+	canvas.SetLineWidth(2)
+	canvas.SetLineWidth(2)
+	canvas.SetStrokeColor(0, 0, 0) // the initial stroke color
+	canvas.Push()
+	canvas.SetColor(0, 0, 1)
+	if w := canvas.LineWidth(); w != 2 {
+		t.Errorf("LineWidth() = %v, expected 2", w)
+	}
+	canvas.Pop()
+	if r, g, b := canvas.FillColor(); r != 1 || g != 0 || b != 0 {
+		t.Errorf("FillColor() after Pop = %v %v %v, expected 1 0 0", r, g, b)
+	}
+	canvas.SetColor(0, 0, 1)
+	canvas.SetFillPattern(Reference{1, 0})
+	canvas.SetColor(0, 0, 1)
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != graphicsStateExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, graphicsStateExpectedOutput)
+	}
+}
+
+func TestTemplateGraphicsStateUnknown(t *testing.T) {
+	doc := New()
+	template := doc.NewTemplate(Rectangle{Point{0, 0}, Point{10, 10}})
+	// A template inherits the state of whatever draws it, so the
+	// default values must still be written.
+	template.SetColor(0, 0, 0)
+	template.SetLineWidth(1)
+	template.Close()
+
+	const expected = "0.00000 0.00000 0.00000 rg\n1.00000 w\n"
+	if output := canvasOutput(t, template); output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+}
+
+func TestCanvasUnbalancedPush(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.Push()
+	if err := canvas.Close(); err == nil {
+		t.Error("Close succeeded with an unmatched Push")
+	}
+	if err := doc.Encode(ioutil.Discard); err == nil {
+		t.Error("Encode succeeded with an unmatched Push")
+	}
+}
+
+func TestEncodeUnclosedPush(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.Push()
+	if err := doc.Encode(ioutil.Discard); err == nil {
+		t.Error("Encode succeeded with an unmatched Push on an unclosed canvas")
+	}
+}
+
+func TestCanvasUnbalancedPop(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.Pop()
+	canvas.SetColor(1, 0, 0)
+	if canvas.Err() == nil {
+		t.Error("Pop without Push did not set an error")
+	}
+	canvas.Close()
+	if output := canvasOutput(t, canvas); output != "" {
+		t.Errorf("Output after error was %q, expected nothing", output)
+	}
+}
+
+func TestCanvasTextWithoutFont(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	text := new(Text)
+	text.Text("Hello")
+	canvas.DrawText(text)
+	if err := canvas.Close(); err != errNoFont {
+		t.Errorf("Close returned %v, expected %v", err, errNoFont)
+	}
+}
+
+const canvasTextStateExpectedOutput = `BT
+/Helvetica 12.00000 Tf
+14.40000 TL
+(a) Tj
+ET
+BT
+(b) Tj
+ET
+q
+BT
+/Helvetica 10.00000 Tf
+12.00000 TL
+(c) Tj
+ET
+Q
+BT
+(d) Tj
+ET
+`
+
+func TestCanvasTextState(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	label := func(s string, size Unit) {
+		text := new(Text)
+		text.SetFont(Helvetica, size)
+		text.Text(s)
+		canvas.DrawText(text)
+	}
+	label("a", 12)
+	label("b", 12)
+	canvas.Push()
+	label("c", 10)
+	canvas.Pop()
+	label("d", 12)
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != canvasTextStateExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, canvasTextStateExpectedOutput)
+	}
+}
+
+func TestCanvasLineStyle(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetLineCap(ButtCap)
+	canvas.SetLineJoin(MiterJoin)
+	canvas.SetMiterLimit(10)
+	canvas.Push()
+	canvas.SetLineCap(RoundCap)
+	canvas.SetLineJoin(BevelJoin)
+	canvas.SetMiterLimit(4)
+	canvas.SetMiterLimit(4)
+	canvas.Pop()
+	canvas.SetLineCap(ButtCap)
+	canvas.SetMiterLimit(4)
+	canvas.Close()
+
+	const expected = "q\n1 J\n2 j\n4.00000 M\nQ\n4.00000 M\n"
+	if output := canvasOutput(t, canvas); output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+	if c, j, m := canvas.LineCap(), canvas.LineJoin(), canvas.MiterLimit(); c != ButtCap || j != MiterJoin || m != 4 {
+		t.Errorf("line style = %v, %v, %v; expected %v, %v, 4", c, j, m, ButtCap, MiterJoin)
 	}
 }
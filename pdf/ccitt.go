@@ -0,0 +1,257 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"image"
+	"image/color"
+	"io"
+)
+
+// This file implements a CCITT Group 4 (ITU-T T.6) encoder for bi-level
+// images.
+
+// ccittParms holds the decode parameters of a CCITTFaxDecode stream.  A
+// negative K selects Group 4 encoding.  See Table 11 of ISO 32000-1.
+type ccittParms struct {
+	K       int
+	Columns int
+	Rows    int
+}
+
+const ccittGroup4 = -1
+
+// Two-dimensional coding mode codes.  See Table 4 of ITU-T T.4.
+const (
+	ccittPass       = "0001"
+	ccittHorizontal = "001"
+	ccittEOL        = "000000000001"
+)
+
+// ccittVertical holds the vertical mode codes for a1 - b1 from -3 to 3.
+var ccittVertical = [7]string{"0000010", "000010", "010", "1", "011", "000011", "0000011"}
+
+// Terminating codes for runs of 0 to 63 pixels.  See Table 2 of ITU-T T.4.
+var (
+	ccittWhiteTerminating = [64]string{
+		"00110101", "000111", "0111", "1000", "1011", "1100", "1110", "1111",
+		"10011", "10100", "00111", "01000", "001000", "000011", "110100", "110101",
+		"101010", "101011", "0100111", "0001100", "0001000", "0010111", "0000011", "0000100",
+		"0101000", "0101011", "0010011", "0100100", "0011000", "00000010", "00000011", "00011010",
+		"00011011", "00010010", "00010011", "00010100", "00010101", "00010110", "00010111", "00101000",
+		"00101001", "00101010", "00101011", "00101100", "00101101", "00000100", "00000101", "00001010",
+		"00001011", "01010010", "01010011", "01010100", "01010101", "00100100", "00100101", "01011000",
+		"01011001", "01011010", "01011011", "01001010", "01001011", "00110010", "00110011", "00110100",
+	}
+	ccittBlackTerminating = [64]string{
+		"0000110111", "010", "11", "10", "011", "0011", "0010", "00011",
+		"000101", "000100", "0000100", "0000101", "0000111", "00000100", "00000111", "000011000",
+		"0000010111", "0000011000", "0000001000", "00001100111", "00001101000", "00001101100", "00000110111", "00000101000",
+		"00000010111", "00000011000", "000011001010", "000011001011", "000011001100", "000011001101", "000001101000", "000001101001",
+		"000001101010", "000001101011", "000011010010", "000011010011", "000011010100", "000011010101", "000011010110", "000011010111",
+		"000001101100", "000001101101", "000011011010", "000011011011", "000001010100", "000001010101", "000001010110", "000001010111",
+		"000001100100", "000001100101", "000001010010", "000001010011", "000000100100", "000000110111", "000000111000", "000000100111",
+		"000000101000", "000001011000", "000001011001", "000000101011", "000000101100", "000001011010", "000001100110", "000001100111",
+	}
+)
+
+// Make-up codes for runs of 64 to 1728 pixels in steps of 64.  See Table 3
+// of ITU-T T.4.
+var (
+	ccittWhiteMakeup = [27]string{
+		"11011", "10010", "010111", "0110111", "00110110", "00110111", "01100100", "01100101",
+		"01101000", "01100111", "011001100", "011001101", "011010010", "011010011", "011010100", "011010101",
+		"011010110", "011010111", "011011000", "011011001", "011011010", "011011011", "010011000", "010011001",
+		"010011010", "011000", "010011011",
+	}
+	ccittBlackMakeup = [27]string{
+		"0000001111", "000011001000", "000011001001", "000001011011", "000000110011", "000000110100", "000000110101", "0000001101100",
+		"0000001101101", "0000001001010", "0000001001011", "0000001001100", "0000001001101", "0000001110010", "0000001110011", "0000001110100",
+		"0000001110101", "0000001110110", "0000001110111", "0000001010010", "0000001010011", "0000001010100", "0000001010101", "0000001011010",
+		"0000001011011", "0000001100100", "0000001100101",
+	}
+)
+
+// ccittExtendedMakeup holds the make-up codes shared by both colors for runs
+// of 1792 to 2560 pixels in steps of 64.  See Table 3a of ITU-T T.4.
+var ccittExtendedMakeup = [13]string{
+	"00000001000", "00000001100", "00000001101", "000000010010", "000000010011", "000000010100", "000000010101",
+	"000000010110", "000000010111", "000000011100", "000000011101", "000000011110", "000000011111",
+}
+
+// g4Encoder encodes rows of a bi-level image with CCITT Group 4 compression.
+// Each row is a slice of booleans that are true for black pixels.
+type g4Encoder struct {
+	w   io.Writer
+	ref []bool // the previous row, initially all white
+	err error
+
+	buf   []byte
+	cur   byte
+	nbits uint
+}
+
+func newG4Encoder(w io.Writer, width int) *g4Encoder {
+	return &g4Encoder{w: w, ref: make([]bool, width)}
+}
+
+// writeCode appends a code, written as a string of '0' and '1' characters.
+func (enc *g4Encoder) writeCode(code string) {
+	for i := 0; i < len(code); i++ {
+		enc.cur = enc.cur<<1 | (code[i] - '0')
+		enc.nbits++
+		if enc.nbits == 8 {
+			enc.buf = append(enc.buf, enc.cur)
+			enc.cur, enc.nbits = 0, 0
+		}
+	}
+}
+
+// writeRun appends the codes for a run of pixels of one color.
+func (enc *g4Encoder) writeRun(n int, black bool) {
+	terminating, makeup := &ccittWhiteTerminating, &ccittWhiteMakeup
+	if black {
+		terminating, makeup = &ccittBlackTerminating, &ccittBlackMakeup
+	}
+	for n >= 2560 {
+		enc.writeCode(ccittExtendedMakeup[len(ccittExtendedMakeup)-1])
+		n -= 2560
+	}
+	if n >= 1792 {
+		enc.writeCode(ccittExtendedMakeup[(n-1792)/64])
+		n %= 64
+	} else if n >= 64 {
+		enc.writeCode(makeup[n/64-1])
+		n %= 64
+	}
+	enc.writeCode(terminating[n])
+}
+
+// pixelColor returns the color of the pixel at x, where pixels left of the
+// row are white.
+func pixelColor(row []bool, x int) bool {
+	return x >= 0 && row[x]
+}
+
+// nextChange returns the position of the first pixel after x that is not
+// black if black is true, or not white if it is false.  If there is none,
+// nextChange returns the width of the row.
+func nextChange(row []bool, x int, black bool) int {
+	for x++; x < len(row); x++ {
+		if row[x] != black {
+			return x
+		}
+	}
+	return len(row)
+}
+
+// encodeRow encodes one row of the image.  See Section 2.2 of ITU-T T.6.
+func (enc *g4Encoder) encodeRow(row []bool) {
+	ref := enc.ref
+	a0, black := -1, false
+	for a0 < len(row) {
+		a1 := nextChange(row, a0, black)
+		a2 := nextChange(row, a1, !black)
+		// b1 is the first changing element on the reference row after a0
+		// whose color is opposite to the color of a0.
+		b1 := a0 + 1
+		for b1 < len(ref) && (ref[b1] == black || pixelColor(ref, b1-1) == ref[b1]) {
+			b1++
+		}
+		b2 := nextChange(ref, b1, !black)
+
+		switch {
+		case b2 < a1:
+			enc.writeCode(ccittPass)
+			a0 = b2
+		case a1-b1 >= -3 && a1-b1 <= 3:
+			enc.writeCode(ccittVertical[a1-b1+3])
+			a0, black = a1, !black
+		default:
+			enc.writeCode(ccittHorizontal)
+			start := a0
+			if start < 0 {
+				start = 0
+			}
+			enc.writeRun(a1-start, black)
+			enc.writeRun(a2-a1, !black)
+			a0 = a2
+		}
+	}
+	copy(enc.ref, row)
+	enc.flush()
+}
+
+// flush writes the complete bytes encoded so far.
+func (enc *g4Encoder) flush() {
+	if enc.err == nil && len(enc.buf) > 0 {
+		_, enc.err = enc.w.Write(enc.buf)
+	}
+	enc.buf = enc.buf[:0]
+}
+
+// close writes the end-of-facsimile-block code and pads the data to a whole
+// number of bytes.
+func (enc *g4Encoder) close() error {
+	enc.writeCode(ccittEOL)
+	enc.writeCode(ccittEOL)
+	if enc.nbits > 0 {
+		enc.writeCode("0000000"[:8-enc.nbits])
+	}
+	enc.flush()
+	return enc.err
+}
+
+// isBlack reports whether a color is closer to black than to white when
+// composited onto a white background.
+func isBlack(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	// Compute the luminance as in color.GrayModel, then add the white
+	// background showing through.
+	y := (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+	return y+(0xffff-a) < 0x8000
+}
+
+// addBilevel adds a bi-level version of an image to the document, encoded
+// with CCITT Group 4 compression.
+func (doc *Document) addBilevel(img image.Image, mask bool) Reference {
+	bd := img.Bounds()
+	st := newImageStream(streamCCITTFax, bd.Dx(), bd.Dy())
+	st.BitsPerComponent = 1
+	st.DecodeParms = &ccittParms{K: ccittGroup4, Columns: bd.Dx(), Rows: bd.Dy()}
+	if mask {
+		st.ColorSpace = nil
+		st.ImageMask = true
+	} else {
+		st.ColorSpace = deviceGrayColorSpace
+	}
+
+	enc := newG4Encoder(st, bd.Dx())
+	row := make([]bool, bd.Dx())
+	for y := bd.Min.Y; y < bd.Max.Y; y++ {
+		for x := range row {
+			row[x] = isBlack(img.At(bd.Min.X+x, y))
+		}
+		enc.encodeRow(row)
+	}
+	enc.close()
+	st.Close()
+	return doc.addImageStream(st)
+}
+
+// AddBilevelImage adds an image to the document as a black and white image
+// with one bit per pixel and returns its PDF file reference.  Pixels are
+// black if they are closer to black than to white, with transparent pixels
+// treated as white.  The image is compressed with CCITT Group 4 compression,
+// which is very effective for scanned documents.
+func (doc *Document) AddBilevelImage(img image.Image) Reference {
+	return doc.addBilevel(img, false)
+}
+
+// AddImageMask adds a stencil mask to the document and returns its PDF file
+// reference.  When drawn with Canvas.DrawImageReference, the mask paints the
+// pixels that AddBilevelImage would make black with the current fill color
+// and leaves all other pixels unchanged.
+func (doc *Document) AddImageMask(img image.Image) Reference {
+	return doc.addBilevel(img, true)
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/ccitt"
+)
+
+// g4RoundTrip encodes rows with the G4 encoder, decodes them again and
+// compares the result.
+func g4RoundTrip(t *testing.T, rows [][]bool) {
+	width, height := len(rows[0]), len(rows)
+	var buf bytes.Buffer
+	enc := newG4Encoder(&buf, width)
+	for _, row := range rows {
+		enc.encodeRow(row)
+	}
+	if err := enc.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	r := ccitt.NewReader(bytes.NewReader(buf.Bytes()), ccitt.MSB, ccitt.Group4, width, height, nil)
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %dx%d image: %v", width, height, err)
+	}
+	stride := (width + 7) / 8
+	if len(data) != stride*height {
+		t.Fatalf("decoded %d bytes, expected %d", len(data), stride*height)
+	}
+	for y, row := range rows {
+		for x, black := range row {
+			// The decoder writes 0 bits for black pixels.
+			if bit := data[y*stride+x/8]>>(7-uint(x%8))&1 == 0; bit != black {
+				t.Fatalf("pixel (%d, %d) decoded as black=%v, expected %v", x, y, bit, black)
+			}
+		}
+	}
+}
+
+func TestG4Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, width := range []int{1, 7, 8, 65, 300} {
+		rows := make([][]bool, 40)
+		for y := range rows {
+			rows[y] = make([]bool, width)
+			for x := range rows[y] {
+				// Mostly copy the previous row to exercise the
+				// vertical and pass modes.
+				if y > 0 && rnd.Intn(8) != 0 {
+					rows[y][x] = rows[y-1][x]
+				} else {
+					rows[y][x] = rnd.Intn(3) == 0
+				}
+			}
+		}
+		g4RoundTrip(t, rows)
+	}
+}
+
+func TestG4LongRuns(t *testing.T) {
+	// Each row alternates runs of increasing length, which covers all
+	// terminating and make-up codes of both colors.
+	const width = 6000
+	var rows [][]bool
+	for start := 0; start < 2700; start += 37 {
+		row := make([]bool, width)
+		x, black := 0, false
+		for n := start; x < width; n += 61 {
+			for i := 0; i < n%2700 && x < width; i++ {
+				row[x] = black
+				x++
+			}
+			black = !black
+		}
+		rows = append(rows, row)
+	}
+	// A run longer than 2560 pixels needs several make-up codes.
+	row := make([]bool, width)
+	for x := 10; x < width-10; x++ {
+		row[x] = true
+	}
+	rows = append(rows, row, make([]bool, width))
+	g4RoundTrip(t, rows)
+}
+
+func TestAddImageMask(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.Black)
+	img.Set(1, 0, color.NRGBA{0, 0, 0, 64}) // mostly transparent
+	img.Set(2, 1, color.NRGBA{40, 40, 40, 255})
+
+	doc := New()
+	ref := doc.AddImageMask(img)
+	st := doc.objects[ref.Number-1].(*imageStream)
+	output, err := st.marshalPDF(nil)
+	if err != nil {
+		t.Fatalf("marshalPDF: %v", err)
+	}
+	const expected = "<< /Type /XObject /Subtype /Image /Length %d /Filter /CCITTFaxDecode /Width 4 /Height 2 /BitsPerComponent 1 /ImageMask true /DecodeParms << /K -1 /Columns 4 /Rows 2 >> >> stream"
+	if !bytes.HasPrefix(output, []byte(fmt.Sprintf(expected, st.Len()))) {
+		t.Errorf("Output was %q, expected prefix %q", output, fmt.Sprintf(expected, st.Len()))
+	}
+
+	r := ccitt.NewReader(bytes.NewReader(st.Bytes()), ccitt.MSB, ccitt.Group4, 4, 2, nil)
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	// Only the first four bits of each row are pixels.
+	if len(data) != 2 || data[0]&0xf0 != 0x70 || data[1]&0xf0 != 0xd0 {
+		t.Errorf("decoded data = %#v, expected rows 0111 and 1101", data)
+	}
+}
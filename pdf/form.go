@@ -0,0 +1,81 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+// formXObject is a self-contained content stream that can be painted any
+// number of times with the Do operator.  See Section 8.10 of ISO 32000-1.
+type formXObject struct {
+	*stream
+	BBox      Rectangle
+	Resources resources
+	Group     *transparencyGroup
+}
+
+type formXObjectInfo struct {
+	Type      name
+	Subtype   name
+	Length    int
+	Filter    name `pdf:",omitempty"`
+	BBox      Rectangle
+	Resources resources
+	Group     *transparencyGroup `pdf:",omitempty"`
+}
+
+func (form *formXObject) marshalPDF(dst []byte) ([]byte, error) {
+	return marshalStream(dst, formXObjectInfo{
+		Type:      xobjectType,
+		Subtype:   formSubtype,
+		Length:    form.Len(),
+		Filter:    form.filter,
+		BBox:      form.BBox,
+		Resources: form.Resources,
+		Group:     form.Group,
+	}, form.Bytes())
+}
+
+// newForm adds a form XObject to the document and returns a canvas that
+// draws into it.
+func (doc *Document) newForm(bbox Rectangle, group *transparencyGroup) *Canvas {
+	form := &formXObject{
+		stream:    newStream(streamFlateDecode),
+		BBox:      bbox,
+		Resources: newResources(),
+		Group:     group,
+	}
+	return doc.addCanvas(&Canvas{
+		doc:       doc,
+		ref:       doc.add(form),
+		contents:  form.stream,
+		resources: &form.Resources,
+		mediaBox:  &form.BBox,
+		cropBox:   &form.BBox,
+		state:     newGraphicsState(0),
+	})
+}
+
+// NewTemplate creates a reusable drawing template with the given bounding
+// box and returns a canvas for drawing its contents.  The template is stored
+// in the document once, no matter how many times it is drawn.
+//
+// Close must be called on the returned canvas once drawing has completed.
+// The template can then be painted with Canvas.DrawTemplate by passing the
+// canvas's Reference.
+func (doc *Document) NewTemplate(bbox Rectangle) *Canvas {
+	return doc.newForm(bbox, nil)
+}
+
+// anonymousXObjectFormat names form XObjects in resource dictionaries.  Each
+// form gets a single name per canvas, however often it is drawn.
+const anonymousXObjectFormat = "__xobject%d__"
+
+// DrawTemplate paints a template created with NewTemplate.  The template's
+// coordinates are transformed by m into the canvas's current coordinate
+// system.
+func (canvas *Canvas) DrawTemplate(template Reference, m Matrix) {
+	name := resourceName(&canvas.resources.XObject, anonymousXObjectFormat, template)
+
+	canvas.Push()
+	canvas.Transform(m[0], m[1], m[2], m[3], m[4], m[5])
+	canvas.command("Do", name)
+	canvas.Pop()
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+const templateExpectedOutput = `q
+1.00000 0.00000 0.00000 1.00000 0.00000 0.00000 cm
+/__xobject0__ Do
+Q
+q
+2.00000 0.00000 0.00000 2.00000 10.00000 20.00000 cm
+/__xobject0__ Do
+Q
+`
+
+func TestTemplate(t *testing.T) {
+	doc := New()
+	template := doc.NewTemplate(Rectangle{Point{0, 0}, Point{100, 50}})
+	template.DrawLine(Point{0, 0}, Point{100, 50})
+	template.Close()
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawTemplate(template.Reference(), IdentityMatrix)
+	canvas.DrawTemplate(template.Reference(), Matrix{2, 0, 0, 2, 10, 20})
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != templateExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, templateExpectedOutput)
+	}
+	if len(canvas.resources.XObject) != 1 {
+		t.Errorf("page has %d XObject resources, expected 1", len(canvas.resources.XObject))
+	}
+	if ref := canvas.resources.XObject["__xobject0__"]; ref != template.Reference() {
+		t.Errorf("XObject __xobject0__ = %v, expected %v", ref, template.Reference())
+	}
+
+	b, err := marshal(nil, doc.objects[template.Reference().Number-1])
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	for _, want := range []string{"/Subtype /Form", "/BBox [ 0.00000 0.00000 100.00000 50.00000 ]"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("form XObject %q does not contain %q", b, want)
+		}
+	}
+}
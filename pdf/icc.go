@@ -0,0 +1,163 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const iccBasedColorSpace name = "ICCBased"
+
+// iccProfileStream is an ICC color profile.  See Section 8.6.5.5 of ISO
+// 32000-1.
+type iccProfileStream struct {
+	*stream
+	N         int
+	Alternate name
+}
+
+type iccProfileStreamInfo struct {
+	N         int
+	Alternate name
+	Length    int
+	Filter    name `pdf:",omitempty"`
+}
+
+func (st *iccProfileStream) marshalPDF(dst []byte) ([]byte, error) {
+	return marshalStream(dst, iccProfileStreamInfo{
+		N:         st.N,
+		Alternate: st.Alternate,
+		Length:    st.Len(),
+		Filter:    st.filter,
+	}, st.Bytes())
+}
+
+// iccDeviceSpaces maps the color space signatures of ICC profiles to the
+// device color spaces with the same number of components.
+var iccDeviceSpaces = map[string]name{
+	"GRAY": deviceGrayColorSpace,
+	"RGB ": deviceRGBColorSpace,
+	"CMYK": deviceCMYKColorSpace,
+}
+
+// deviceComponents is the number of color components of each device color
+// space.
+var deviceComponents = map[name]int{
+	deviceGrayColorSpace: 1,
+	deviceRGBColorSpace:  3,
+	deviceCMYKColorSpace: 4,
+}
+
+var errNotICCProfile = errors.New("pdf: not an ICC profile")
+
+// AddICCProfile reads an ICC color profile, typically from a .icc or .icm
+// file, and adds it to the document.  The returned reference selects the
+// profile in ImageOptions.ColorProfile and Canvas.SetICCColor.  Gray, RGB and
+// CMYK profiles are supported.
+//
+// Like images, profiles are stored only once: adding a profile that is
+// identical to one added before returns the existing reference.
+func (doc *Document) AddICCProfile(r io.Reader) (Reference, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Reference{}, err
+	}
+	// The profile header is 128 bytes long and holds the signature "acsp"
+	// at offset 36 and the color space of the data at offset 16.
+	if len(data) < 128 || !bytes.Equal(data[36:40], []byte("acsp")) {
+		return Reference{}, errNotICCProfile
+	}
+	alt, ok := iccDeviceSpaces[string(data[16:20])]
+	if !ok {
+		return Reference{}, fmt.Errorf("pdf: unsupported ICC profile color space %q", data[16:20])
+	}
+
+	sum := sha256.Sum256(data)
+	if ref, ok := doc.iccProfiles[sum]; ok {
+		return ref, nil
+	}
+	st := &iccProfileStream{
+		stream:    newStream(streamFlateDecode),
+		N:         deviceComponents[alt],
+		Alternate: alt,
+	}
+	st.Write(data)
+	st.Close()
+	ref := doc.add(st)
+	doc.iccProfiles[sum] = ref
+	return ref, nil
+}
+
+// iccProfile returns the profile stream that profile refers to, or nil if it
+// does not refer to a profile in the document.
+func (doc *Document) iccProfile(profile Reference) *iccProfileStream {
+	if profile.Number == 0 || int(profile.Number) > len(doc.objects) {
+		return nil
+	}
+	st, _ := doc.objects[profile.Number-1].(*iccProfileStream)
+	return st
+}
+
+// withICCProfile returns an image color space with its device color space
+// replaced by an ICC-based color space using profile.  The color space is
+// returned unchanged if profile does not have the same number of components.
+func (doc *Document) withICCProfile(cs interface{}, profile Reference) interface{} {
+	st := doc.iccProfile(profile)
+	if st == nil {
+		return cs
+	}
+	switch cs := cs.(type) {
+	case name:
+		if deviceComponents[cs] == st.N {
+			return []interface{}{iccBasedColorSpace, profile}
+		}
+	case []interface{}:
+		// Indexed color spaces hold their base color space.
+		if len(cs) == 4 && cs[0] == indexedColorSpace {
+			return []interface{}{indexedColorSpace, doc.withICCProfile(cs[1], profile), cs[2], cs[3]}
+		}
+	}
+	return cs
+}
+
+// setICCColor selects the ICC-based color space using profile with the
+// operator csOp and sets the color with colorOp.
+func (canvas *Canvas) setICCColor(csOp, colorOp string, profile Reference, c []float32) {
+	st := canvas.doc.iccProfile(profile)
+	if st == nil {
+		canvas.setErr(errors.New("pdf: color profile is not in the document"))
+		return
+	}
+	if len(c) != st.N {
+		canvas.setErr(fmt.Errorf("pdf: color profile takes %d components, got %d", st.N, len(c)))
+		return
+	}
+	cs := resourceName(&canvas.resources.ColorSpace, anonymousColorSpaceFormat, [2]interface{}{iccBasedColorSpace, profile})
+	canvas.command(csOp, cs)
+	args := make([]interface{}, len(c))
+	for i, v := range c {
+		args[i] = v
+	}
+	canvas.command(colorOp, args...)
+}
+
+// SetICCColor changes the current fill color to the color with the given
+// components in the color space described by an ICC profile added with
+// AddICCProfile.  The number of components must match the profile.
+func (canvas *Canvas) SetICCColor(profile Reference, c ...float32) {
+	canvas.setICCColor("cs", "sc", profile, c)
+	canvas.state.known &^= knownFillColor
+}
+
+// SetICCStrokeColor changes the current stroke color to the color with the
+// given components in the color space described by an ICC profile added with
+// AddICCProfile.  The number of components must match the profile.
+func (canvas *Canvas) SetICCStrokeColor(profile Reference, c ...float32) {
+	canvas.setICCColor("CS", "SC", profile, c)
+	canvas.state.known &^= knownStrokeColor
+}
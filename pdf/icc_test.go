@@ -0,0 +1,87 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"testing"
+)
+
+// fakeICCProfile returns a profile header with the given color space
+// signature.  It is enough for AddICCProfile, but not a usable profile.
+func fakeICCProfile(space string) []byte {
+	data := make([]byte, 128)
+	copy(data[16:], space)
+	copy(data[36:], "acsp")
+	return data
+}
+
+func TestAddICCProfile(t *testing.T) {
+	doc := New()
+	rgb, err := doc.AddICCProfile(bytes.NewReader(fakeICCProfile("RGB ")))
+	if err != nil {
+		t.Fatalf("AddICCProfile: %v", err)
+	}
+	if again, _ := doc.AddICCProfile(bytes.NewReader(fakeICCProfile("RGB "))); again != rgb {
+		t.Errorf("identical profile was added as %v, expected %v", again, rgb)
+	}
+	st := doc.iccProfile(rgb)
+	if st == nil || st.N != 3 || st.Alternate != deviceRGBColorSpace {
+		t.Errorf("profile = %+v, expected 3 components with alternate %s", st, deviceRGBColorSpace)
+	}
+	if string(streamData(t, st.stream)) != string(fakeICCProfile("RGB ")) {
+		t.Error("profile data does not round-trip")
+	}
+
+	for _, data := range [][]byte{[]byte("not a profile"), fakeICCProfile("Lab ")} {
+		if _, err := doc.AddICCProfile(bytes.NewReader(data)); err == nil {
+			t.Errorf("AddICCProfile(%q) succeeded", data[16:20])
+		}
+	}
+}
+
+func TestImageColorProfile(t *testing.T) {
+	doc := New()
+	rgb, _ := doc.AddICCProfile(bytes.NewReader(fakeICCProfile("RGB ")))
+	opts := &ImageOptions{ColorProfile: rgb}
+	expected := fmt.Sprintf("[ /ICCBased %d 0 R ]", rgb.Number)
+
+	img := doc.AddImageWithOptions(image.NewRGBA(image.Rect(0, 0, 2, 2)), opts)
+	cs := doc.objects[img.Number-1].(*imageStream).ColorSpace
+	if output, _ := marshal(nil, cs); string(output) != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+
+	gray := doc.AddImageWithOptions(image.NewGray(image.Rect(0, 0, 2, 2)), opts)
+	if cs := doc.objects[gray.Number-1].(*imageStream).ColorSpace; cs != deviceGrayColorSpace {
+		t.Errorf("gray image has color space %v, expected %v", cs, deviceGrayColorSpace)
+	}
+}
+
+func TestSetICCColor(t *testing.T) {
+	doc := New()
+	cmyk, _ := doc.AddICCProfile(bytes.NewReader(fakeICCProfile("CMYK")))
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetICCColor(cmyk, 0, 1, 1, 0)
+	canvas.SetICCStrokeColor(cmyk, 1, 0, 0, 0)
+	canvas.SetColor(0, 0, 0)
+	canvas.Close()
+
+	const expected = "/__cs0__ cs\n0.00000 1.00000 1.00000 0.00000 sc\n" +
+		"/__cs0__ CS\n1.00000 0.00000 0.00000 0.00000 SC\n" +
+		"0.00000 0.00000 0.00000 rg\n"
+	if output := canvasOutput(t, canvas); output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+	if len(canvas.resources.ColorSpace) != 1 {
+		t.Errorf("page has %d color space resources, expected 1", len(canvas.resources.ColorSpace))
+	}
+
+	canvas = doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetICCColor(cmyk, 0, 0, 0)
+	if canvas.Err() == nil {
+		t.Error("SetICCColor accepted the wrong number of components")
+	}
+}
@@ -9,7 +9,9 @@ import (
 )
 
 const (
-	deviceRGBColorSpace name = "DeviceRGB"
+	deviceRGBColorSpace  name = "DeviceRGB"
+	deviceGrayColorSpace name = "DeviceGray"
+	indexedColorSpace    name = "Indexed"
 )
 
 type imageStream struct {
@@ -17,7 +19,13 @@ type imageStream struct {
 	Width            int
 	Height           int
 	BitsPerComponent int
-	ColorSpace       name
+	ColorSpace       interface{}
+	ImageMask        bool
+	Decode           []float32
+	DecodeParms      interface{}
+	Interpolate      bool
+	Mask             interface{}
+	SMask            interface{}
 }
 
 type imageStreamInfo struct {
@@ -28,7 +36,13 @@ type imageStreamInfo struct {
 	Width            int
 	Height           int
 	BitsPerComponent int
-	ColorSpace       name
+	ColorSpace       interface{} `pdf:",omitempty"`
+	ImageMask        bool        `pdf:",omitempty"`
+	Decode           []float32   `pdf:",omitempty"`
+	DecodeParms      interface{} `pdf:",omitempty"`
+	Interpolate      bool        `pdf:",omitempty"`
+	Mask             interface{} `pdf:",omitempty"`
+	SMask            interface{} `pdf:",omitempty"`
 }
 
 func newImageStream(filter name, w, h int) *imageStream {
@@ -41,8 +55,17 @@ func newImageStream(filter name, w, h int) *imageStream {
 	}
 }
 
-func (st *imageStream) marshalPDF(dst []byte) ([]byte, error) {
-	return marshalStream(dst, imageStreamInfo{
+// newEncodedImageStream returns an image stream for data that is already
+// encoded with filter.
+func newEncodedImageStream(filter name, w, h int) *imageStream {
+	st := newImageStream(streamNoFilter, w, h)
+	st.stream = newEncodedStream(filter)
+	return st
+}
+
+// info returns the stream dictionary of the image.
+func (st *imageStream) info() imageStreamInfo {
+	return imageStreamInfo{
 		Type:             xobjectType,
 		Subtype:          imageSubtype,
 		Length:           st.Len(),
@@ -51,7 +74,177 @@ func (st *imageStream) marshalPDF(dst []byte) ([]byte, error) {
 		Height:           st.Height,
 		BitsPerComponent: st.BitsPerComponent,
 		ColorSpace:       st.ColorSpace,
-	}, st.Bytes())
+		ImageMask:        st.ImageMask,
+		Decode:           st.Decode,
+		DecodeParms:      st.DecodeParms,
+		Interpolate:      st.Interpolate,
+		Mask:             st.Mask,
+		SMask:            st.SMask,
+	}
+}
+
+func (st *imageStream) marshalPDF(dst []byte) ([]byte, error) {
+	return marshalStream(dst, st.info(), st.Bytes())
+}
+
+// imageFormat describes how encodeImage stores the pixels of an image.
+type imageFormat struct {
+	colorSpace       interface{}
+	components       int
+	bitsPerComponent int
+}
+
+// dataSize returns the number of bytes needed to store an image of the given
+// size.  Each row starts on a byte boundary.
+func (f imageFormat) dataSize(width, height int) int {
+	return (width*f.components*f.bitsPerComponent + 7) / 8 * height
+}
+
+// usesPredictor reports whether image data in the format compresses better
+// with PNG predictors.  Like PNG encoders, we do not use predictors for
+// indexed images or for images with less than 8 bits per component.
+func (f imageFormat) usesPredictor() bool {
+	if f.bitsPerComponent < 8 {
+		return false
+	}
+	_, indexed := f.colorSpace.([]interface{})
+	return !indexed
+}
+
+// formatOf returns the format that encodeImage uses for an image.  Images are
+// stored in their native color space and bit depth where PDF supports them,
+// and as 8-bit RGB otherwise.
+func formatOf(img image.Image) imageFormat {
+	switch i := img.(type) {
+	case *image.Gray:
+		return imageFormat{deviceGrayColorSpace, 1, 8}
+	case *image.Gray16:
+		return imageFormat{deviceGrayColorSpace, 1, 16}
+	case *image.RGBA64, *image.NRGBA64:
+		return imageFormat{deviceRGBColorSpace, 3, 16}
+	case *image.CMYK:
+		return imageFormat{deviceCMYKColorSpace, 4, 8}
+	case *image.Paletted:
+		if len(i.Palette) > 0 && len(i.Palette) <= 256 {
+			return imageFormat{indexedPalette(i.Palette), 1, paletteBits(len(i.Palette))}
+		}
+	}
+	return imageFormat{deviceRGBColorSpace, 3, 8}
+}
+
+// encodeImage writes the data of an image in PDF format, using the fastest
+// encoder available for the image's type.  The data is laid out as described
+// by formatOf.
+func encodeImage(w io.Writer, img image.Image) error {
+	switch i := img.(type) {
+	case *image.RGBA:
+		return encodeRGBAStream(w, i)
+	case *image.NRGBA:
+		return encodeNRGBAStream(w, i)
+	case *image.YCbCr:
+		return encodeYCbCrStream(w, i)
+	case *image.Gray:
+		return encodeRows(w, i.Pix, i.Stride, i.Rect.Dx(), i.Rect.Dy(), func(dst, src []byte) {
+			copy(dst, src)
+		})
+	case *image.Gray16:
+		return encodeRows(w, i.Pix, i.Stride, 2*i.Rect.Dx(), i.Rect.Dy(), func(dst, src []byte) {
+			copy(dst, src)
+		})
+	case *image.RGBA64:
+		return encodeRows(w, i.Pix, i.Stride, 6*i.Rect.Dx(), i.Rect.Dy(), encodeRGBA64Row)
+	case *image.NRGBA64:
+		return encodeRows(w, i.Pix, i.Stride, 6*i.Rect.Dx(), i.Rect.Dy(), func(dst, src []byte) {
+			for x := 0; 6*x < len(dst); x++ {
+				copy(dst[6*x:6*x+6], src[8*x:8*x+6])
+			}
+		})
+	case *image.CMYK:
+		return encodeRows(w, i.Pix, i.Stride, 4*i.Rect.Dx(), i.Rect.Dy(), func(dst, src []byte) {
+			copy(dst, src)
+		})
+	case *image.Paletted:
+		if f := formatOf(i); f.components == 1 {
+			bits := f.bitsPerComponent
+			return encodeRows(w, i.Pix, i.Stride, f.dataSize(i.Rect.Dx(), 1), i.Rect.Dy(), func(dst, src []byte) {
+				packBits(dst, src[:i.Rect.Dx()], bits)
+			})
+		}
+	}
+	return encodeImageStream(w, img)
+}
+
+// encodeRows writes height rows of n bytes each, converted by the function
+// encode from rows of pix that are stride bytes apart.
+func encodeRows(w io.Writer, pix []byte, stride, n, height int, encode func(dst, src []byte)) error {
+	row := make([]byte, n)
+	for y := 0; y < height; y++ {
+		encode(row, pix[y*stride:])
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeRGBA64Row converts a row of premultiplied 16-bit RGBA pixels into
+// 16-bit RGB.
+func encodeRGBA64Row(dst, src []byte) {
+	for x := 0; 6*x < len(dst); x++ {
+		p := src[8*x : 8*x+8]
+		a := uint32(p[6])<<8 | uint32(p[7])
+		for c := 0; c < 3; c++ {
+			v := uint32(0)
+			if a != 0 {
+				v = (uint32(p[2*c])<<8 | uint32(p[2*c+1])) * 0xffff / a
+			}
+			dst[6*x+2*c] = uint8(v >> 8)
+			dst[6*x+2*c+1] = uint8(v)
+		}
+	}
+}
+
+// paletteBits returns the smallest number of bits per index that can
+// address a palette with n colors.
+func paletteBits(n int) int {
+	switch {
+	case n <= 2:
+		return 1
+	case n <= 4:
+		return 2
+	case n <= 16:
+		return 4
+	}
+	return 8
+}
+
+// packBits packs values of the given bit depth into dst, most significant
+// bits first.
+func packBits(dst, src []byte, bits int) {
+	if bits == 8 {
+		copy(dst, src)
+		return
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	perByte := 8 / bits
+	for i, v := range src {
+		shift := uint(8 - bits*(i%perByte+1))
+		dst[i/perByte] |= v << shift
+	}
+}
+
+// indexedPalette returns an Indexed color space that maps palette indices to
+// the palette's colors in device RGB space.  See Section 8.6.6.3 of ISO
+// 32000-1.
+func indexedPalette(p color.Palette) []interface{} {
+	lookup := make([]byte, 0, 3*len(p))
+	for _, c := range p {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		lookup = append(lookup, nc.R, nc.G, nc.B)
+	}
+	return []interface{}{indexedColorSpace, deviceRGBColorSpace, len(p) - 1, string(lookup)}
 }
 
 // encodeImageStream writes RGB data from an image in PDF format.
@@ -133,3 +326,190 @@ func encodeYCbCrStream(w io.Writer, img *image.YCbCr) error {
 	_, err := w.Write(buf)
 	return err
 }
+
+// newFlateImageStream returns a closed, Flate-compressed image stream that
+// holds the color data of an image.
+func newFlateImageStream(img image.Image) *imageStream {
+	bd := img.Bounds()
+	st := newImageStream(streamFlateDecode, bd.Dx(), bd.Dy())
+	format := formatOf(img)
+	st.ColorSpace, st.BitsPerComponent = format.colorSpace, format.bitsPerComponent
+	if format.usesPredictor() {
+		st.DecodeParms = predictorParms(format, bd.Dx())
+		encodeImage(newPredictorWriter(st, format, bd.Dx()), img)
+	} else {
+		encodeImage(st, img)
+	}
+	st.Close()
+	return st
+}
+
+// newSoftMaskStream returns a closed image stream that holds the alpha
+// channel of an image.
+func newSoftMaskStream(img image.Image) *imageStream {
+	bd := img.Bounds()
+	format := imageFormat{deviceGrayColorSpace, 1, 8}
+	mask := newImageStream(streamFlateDecode, bd.Dx(), bd.Dy())
+	mask.ColorSpace = deviceGrayColorSpace
+	mask.DecodeParms = predictorParms(format, bd.Dx())
+	encodeAlphaStream(newPredictorWriter(mask, format, bd.Dx()), img)
+	mask.Close()
+	return mask
+}
+
+// isOpaque reports whether every pixel of an image is fully opaque.
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bd := img.Bounds()
+	for y := bd.Min.Y; y < bd.Max.Y; y++ {
+		for x := bd.Min.X; x < bd.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// colorKey returns the palette index of the only transparent color of a
+// paletted image.  It reports false unless the image is stored with an
+// indexed color space and exactly one palette entry is fully transparent
+// while all others are fully opaque, so that the index alone can mask the
+// image.  See Section 8.9.6.4 of ISO 32000-1.
+func colorKey(img image.Image) (int, bool) {
+	p, ok := img.(*image.Paletted)
+	if !ok || len(p.Palette) == 0 || len(p.Palette) > 256 {
+		return 0, false
+	}
+	key := -1
+	for i, c := range p.Palette {
+		switch _, _, _, a := c.RGBA(); a {
+		case 0xffff:
+		case 0:
+			if key >= 0 {
+				return 0, false
+			}
+			key = i
+		default:
+			return 0, false
+		}
+	}
+	return key, key >= 0
+}
+
+// encodeAlphaStream writes the alpha channel of an image as DeviceGray data
+// for use as a soft mask.
+func encodeAlphaStream(w io.Writer, img image.Image) error {
+	bd := img.Bounds()
+	var pix []uint8
+	var stride int
+	switch i := img.(type) {
+	case *image.RGBA:
+		pix, stride = i.Pix, i.Stride
+	case *image.NRGBA:
+		pix, stride = i.Pix, i.Stride
+	}
+	row := make([]byte, bd.Dx())
+	for y := bd.Min.Y; y < bd.Max.Y; y++ {
+		if pix != nil {
+			off := (y - bd.Min.Y) * stride
+			for x := range row {
+				row[x] = pix[off+4*x+3]
+			}
+		} else {
+			for x := range row {
+				_, _, _, a := img.At(bd.Min.X+x, y).RGBA()
+				row[x] = uint8(a >> 8)
+			}
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineImageMaxBytes is the largest amount of uncompressed image data that
+// DrawImage writes inline.  Section 8.9.7 of ISO 32000-1 recommends inline
+// images of no more than 4 KB.
+const inlineImageMaxBytes = 4096
+
+// isSmallImage reports whether an image is small enough to be written
+// inline.
+func isSmallImage(img image.Image) bool {
+	bd := img.Bounds()
+	return formatOf(img).dataSize(bd.Dx(), bd.Dy()) <= inlineImageMaxBytes
+}
+
+// Abbreviated keys and values used in inline image dictionaries.  See Table
+// 93 and Table 94 of ISO 32000-1.
+const (
+	inlineWidth            name = "W"
+	inlineHeight           name = "H"
+	inlineBitsPerComponent name = "BPC"
+	inlineColorSpaceKey    name = "CS"
+	inlineFilter           name = "F"
+	inlineFlateDecode      name = "Fl"
+)
+
+// inlineColorSpaces maps color space names to their abbreviations in inline
+// images.
+var inlineColorSpaces = map[name]name{
+	deviceGrayColorSpace: "G",
+	deviceRGBColorSpace:  "RGB",
+	deviceCMYKColorSpace: "CMYK",
+	indexedColorSpace:    "I",
+}
+
+// inlineColorSpace abbreviates a color space for use in an inline image.
+func inlineColorSpace(cs interface{}) interface{} {
+	switch cs := cs.(type) {
+	case name:
+		if abbr, ok := inlineColorSpaces[cs]; ok {
+			return abbr
+		}
+	case []interface{}:
+		abbr := make([]interface{}, len(cs))
+		for i, v := range cs {
+			abbr[i] = inlineColorSpace(v)
+		}
+		return abbr
+	}
+	return cs
+}
+
+// DrawInlineImage paints a raster image at the given location and scaled to
+// the given dimensions.  Unlike DrawImage, the image data is always stored in
+// the content stream itself rather than as a separate object, which saves
+// space for tiny images like icons but stores the image again every time it
+// is drawn.  Inline images cannot have soft masks, so any transparency in img
+// is ignored.
+func (canvas *Canvas) DrawInlineImage(img image.Image, rect Rectangle) {
+	bd := img.Bounds()
+	format := formatOf(img)
+	st := newStream(streamFlateDecode)
+	if err := encodeImage(st, img); err != nil {
+		canvas.setErr(err)
+		return
+	}
+	if err := st.Close(); err != nil {
+		canvas.setErr(err)
+		return
+	}
+
+	canvas.Push()
+	canvas.Transform(float32(rect.Dx()), 0, 0, float32(rect.Dy()), float32(rect.Min.X), float32(rect.Min.Y))
+	canvas.command("BI")
+	canvas.command("ID",
+		inlineWidth, bd.Dx(),
+		inlineHeight, bd.Dy(),
+		inlineBitsPerComponent, format.bitsPerComponent,
+		inlineColorSpaceKey, inlineColorSpace(format.colorSpace),
+		inlineFilter, inlineFlateDecode)
+	// The data is followed by white space to separate it from EI.
+	canvas.write(append(st.Bytes(), '\n'))
+	canvas.command("EI")
+	canvas.Pop()
+}
@@ -2,6 +2,7 @@ package pdf
 
 import (
 	"bytes"
+	"compress/zlib"
 	"image"
 	"image/color"
 	"image/draw"
@@ -9,6 +10,7 @@ import (
 	"image/png"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"golang.org/x/image/bmp"
@@ -218,3 +220,268 @@ func TestEncodeYCbCrStreamGeneric(t *testing.T) {
 	encodeImageStream(&buf, img)
 	expectImageBuffer(buf.Bytes(), r, c, t)
 }
+
+const inlineImagePrefix = `q
+2.00000 0.00000 0.00000 1.00000 10.00000 20.00000 cm
+BI
+/W 2 /H 1 /BPC 8 /CS /RGB /F /Fl ID
+`
+
+func TestDrawInlineImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	img.Set(1, 0, color.NRGBA{G: 128, B: 64, A: 255})
+
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawImage(img, Rectangle{Point{10, 20}, Point{12, 21}})
+	canvas.Close()
+
+	if len(canvas.resources.XObject) != 0 {
+		t.Errorf("small image was added as an XObject")
+	}
+	output := canvasOutput(t, canvas)
+	if !strings.HasPrefix(output, inlineImagePrefix) {
+		t.Fatalf("Output was %q, expected prefix %q", output, inlineImagePrefix)
+	}
+	const suffix = "\nEI\nQ\n"
+	if !strings.HasSuffix(output, suffix) {
+		t.Fatalf("Output was %q, expected suffix %q", output, suffix)
+	}
+	r, err := zlib.NewReader(strings.NewReader(output[len(inlineImagePrefix) : len(output)-len(suffix)]))
+	if err != nil {
+		t.Fatalf("zlib.NewReader: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading image data: %v", err)
+	}
+	if expected := []byte{255, 0, 0, 0, 128, 64}; !bytes.Equal(data, expected) {
+		t.Errorf("image data = %v, expected %v", data, expected)
+	}
+}
+
+func TestDrawLargeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawImage(img, Rectangle{Point{0, 0}, Point{64, 64}})
+	canvas.Close()
+
+	if len(canvas.resources.XObject) != 1 {
+		t.Errorf("large image was not added as an XObject")
+	}
+	if output := canvasOutput(t, canvas); strings.Contains(output, "BI") {
+		t.Errorf("large image was drawn inline: %q", output)
+	}
+}
+
+// streamData returns the decompressed contents of a flate-encoded stream.
+func streamData(t *testing.T, st *stream) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(st.Bytes()))
+	if err != nil {
+		t.Fatalf("zlib.NewReader: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	return data
+}
+
+func TestAddImageSoftMask(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	img.Set(1, 0, color.NRGBA{G: 255, A: 128})
+	img.Set(2, 0, color.NRGBA{B: 255, A: 0})
+
+	doc := New()
+	ref := doc.AddImage(img)
+	st := doc.objects[ref.Number-1].(*imageStream)
+	maskRef, ok := st.SMask.(Reference)
+	if !ok {
+		t.Fatalf("image has no soft mask")
+	}
+	mask := doc.objects[maskRef.Number-1].(*imageStream)
+	if mask.ColorSpace != deviceGrayColorSpace {
+		t.Errorf("mask color space = %v, expected %v", mask.ColorSpace, deviceGrayColorSpace)
+	}
+	if data, expected := unpredict(t, streamData(t, mask.stream), 3, 1), []byte{255, 128, 0}; !bytes.Equal(data, expected) {
+		t.Errorf("mask data = %v, expected %v", data, expected)
+	}
+	if data, expected := unpredict(t, streamData(t, st.stream), 9, 3), []byte{255, 0, 0, 0, 255, 0, 0, 0, 255}; !bytes.Equal(data, expected) {
+		t.Errorf("image data = %v, expected %v", data, expected)
+	}
+}
+
+func TestAddOpaqueImageHasNoSoftMask(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+
+	doc := New()
+	ref := doc.AddImage(img)
+	if st := doc.objects[ref.Number-1].(*imageStream); st.SMask != nil {
+		t.Errorf("opaque image has soft mask %v", st.SMask)
+	}
+}
+
+func TestAddImageColorKey(t *testing.T) {
+	palette := color.Palette{color.White, color.Transparent, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, 3, 1), palette)
+	copy(img.Pix, []byte{0, 1, 2})
+
+	doc := New()
+	ref := doc.AddImage(img)
+	st := doc.objects[ref.Number-1].(*imageStream)
+	if st.SMask != nil {
+		t.Errorf("color-keyed image has soft mask %v", st.SMask)
+	}
+	if output, _ := marshal(nil, st.Mask); string(output) != "[ 1 1 ]" {
+		t.Errorf("Output was %q, expected %q", output, "[ 1 1 ]")
+	}
+
+	// A translucent palette entry needs a soft mask.
+	img.Palette = append(img.Palette, color.NRGBA{A: 128})
+	img.Pix[0] = 3
+	ref = doc.AddImage(img)
+	st = doc.objects[ref.Number-1].(*imageStream)
+	if st.Mask != nil || st.SMask == nil {
+		t.Errorf("Mask = %v and SMask = %v, expected only a soft mask", st.Mask, st.SMask)
+	}
+}
+
+func TestEncodeAlphaStreamGeneric(t *testing.T) {
+	img := image.NewAlpha(image.Rect(0, 0, 2, 1))
+	img.SetAlpha(0, 0, color.Alpha{A: 10})
+	img.SetAlpha(1, 0, color.Alpha{A: 200})
+
+	var buf bytes.Buffer
+	encodeAlphaStream(&buf, img)
+	if expected := []byte{10, 200}; !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("alpha data = %v, expected %v", buf.Bytes(), expected)
+	}
+}
+
+func TestEncodeNativeFormats(t *testing.T) {
+	r := image.Rect(0, 0, 2, 1)
+	gray := image.NewGray(r)
+	gray.Pix = []byte{0x12, 0xfe}
+	gray16 := image.NewGray16(r)
+	gray16.Pix = []byte{0x12, 0x34, 0xfe, 0xdc}
+	rgba64 := image.NewRGBA64(r)
+	rgba64.SetRGBA64(0, 0, color.RGBA64{0x1000, 0x2000, 0x3000, 0xffff})
+	rgba64.SetRGBA64(1, 0, color.RGBA64{0x4000, 0x2000, 0, 0x8000})
+	nrgba64 := image.NewNRGBA64(r)
+	nrgba64.SetNRGBA64(0, 0, color.NRGBA64{0x1234, 0x5678, 0x9abc, 0x1111})
+	cmyk := image.NewCMYK(r)
+	cmyk.Pix = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	paletted := image.NewPaletted(image.Rect(0, 0, 5, 2), color.Palette{color.Black, color.White, color.RGBA{255, 0, 0, 255}})
+	paletted.Pix = []byte{0, 1, 2, 1, 0, 2, 2, 2, 2, 2}
+
+	tests := []struct {
+		Image            image.Image
+		ColorSpace       string
+		BitsPerComponent int
+		Data             []byte
+	}{
+		{gray, "/DeviceGray", 8, []byte{0x12, 0xfe}},
+		{gray16, "/DeviceGray", 16, []byte{0x12, 0x34, 0xfe, 0xdc}},
+		{rgba64, "/DeviceRGB", 16, []byte{0x10, 0x00, 0x20, 0x00, 0x30, 0x00, 0x7f, 0xff, 0x3f, 0xff, 0x00, 0x00}},
+		{nrgba64, "/DeviceRGB", 16, []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0, 0, 0, 0, 0, 0}},
+		{cmyk, "/DeviceCMYK", 8, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{paletted, "[ /Indexed /DeviceRGB 2 <000000FFFFFFFF0000> ]", 2, []byte{0x19, 0x00, 0xaa, 0x80}},
+	}
+	for i, tt := range tests {
+		f := formatOf(tt.Image)
+		if cs, _ := marshal(nil, f.colorSpace); string(cs) != tt.ColorSpace {
+			t.Errorf("%d. color space = %s, expected %s", i, cs, tt.ColorSpace)
+		}
+		if f.bitsPerComponent != tt.BitsPerComponent {
+			t.Errorf("%d. bits per component = %d, expected %d", i, f.bitsPerComponent, tt.BitsPerComponent)
+		}
+		var buf bytes.Buffer
+		encodeImage(&buf, tt.Image)
+		if !bytes.Equal(buf.Bytes(), tt.Data) {
+			t.Errorf("%d. data = %#v, expected %#v", i, buf.Bytes(), tt.Data)
+		}
+		bd := tt.Image.Bounds()
+		if n := f.dataSize(bd.Dx(), bd.Dy()); n != len(tt.Data) {
+			t.Errorf("%d. dataSize = %d, expected %d", i, n, len(tt.Data))
+		}
+	}
+}
+
+func TestEncodeSubImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 3, 3))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	var buf bytes.Buffer
+	encodeImage(&buf, img.SubImage(image.Rect(1, 1, 3, 3)))
+	if expected := []byte{4, 5, 7, 8}; !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("data = %v, expected %v", buf.Bytes(), expected)
+	}
+}
+
+func TestAddImageDeduplicates(t *testing.T) {
+	newImage := func(c color.Color) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.ZP, draw.Src)
+		return img
+	}
+	doc := New()
+	logo := doc.AddImage(newImage(color.NRGBA{R: 255, A: 128}))
+	if again := doc.AddImage(newImage(color.NRGBA{R: 255, A: 128})); again != logo {
+		t.Errorf("identical image added as %v, expected %v", again, logo)
+	}
+	if other := doc.AddImage(newImage(color.NRGBA{G: 255, A: 128})); other == logo {
+		t.Errorf("different image reused reference %v", logo)
+	}
+	// The two images with the same alpha channel share their soft mask.
+	if n := len(doc.objects); n != 4 {
+		t.Errorf("document has %d objects, expected 4", n)
+	}
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	for i := 0; i < 3; i++ {
+		canvas.DrawImage(newImage(color.NRGBA{R: 255, A: 128}), Rectangle{Point{0, 0}, Point{64, 64}})
+	}
+	for _, ref := range canvas.resources.XObject {
+		if ref != logo {
+			t.Errorf("page draws image %v, expected %v", ref, logo)
+		}
+	}
+}
+
+func TestDrawImageReferenceNamesOnce(t *testing.T) {
+	doc := New()
+	ref := doc.AddImage(image.NewGray(image.Rect(0, 0, 2, 2)))
+	template := doc.NewTemplate(Rectangle{Point{0, 0}, Point{10, 10}})
+	template.Close()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawImageReference(ref, Rectangle{Point{0, 0}, Point{10, 10}})
+	canvas.DrawTemplate(template.Reference(), IdentityMatrix)
+	canvas.DrawImageReference(ref, Rectangle{Point{10, 10}, Point{20, 20}})
+	canvas.Close()
+
+	if n := len(canvas.resources.XObject); n != 2 {
+		t.Errorf("page has %d XObject resources, expected 2: %v", n, canvas.resources.XObject)
+	}
+	if output := canvasOutput(t, canvas); strings.Count(output, "/__image0__ Do") != 2 {
+		t.Errorf("Output %q does not draw __image0__ twice", output)
+	}
+}
+
+func TestAddImageStreamReportsError(t *testing.T) {
+	doc := New()
+	st := newImageStream(streamNoFilter, 1, 1)
+	st.ColorSpace = make(chan int)
+	st.Write([]byte{0, 0, 0})
+	st.Close()
+	doc.addImageStream(st)
+	doc.NewPage(USLetterWidth, USLetterHeight).Close()
+	if err := doc.Encode(ioutil.Discard); err == nil {
+		t.Error("Encode succeeded with an unmarshalable image dictionary")
+	}
+}
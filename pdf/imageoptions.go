@@ -0,0 +1,376 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+)
+
+// A ResampleFilter selects how pixels are computed when an image is
+// downsampled.
+type ResampleFilter int
+
+// Resampling filters
+const (
+	// BoxFilter averages all source pixels covered by each new pixel.  It
+	// gives the best results when reducing the size of photos.
+	BoxFilter ResampleFilter = iota
+	// BilinearFilter interpolates between the four nearest source pixels.
+	BilinearFilter
+	// NearestNeighborFilter uses the nearest source pixel.  It keeps hard
+	// edges, for example in pixel art and screenshots, and is the fastest.
+	NearestNeighborFilter
+)
+
+// ImageOptions controls how an image is stored in a document.  The zero value
+// stores the image unchanged and without loss.
+type ImageOptions struct {
+	// Width and Height are the size at which the image is drawn, in the
+	// default coordinate system of the page.  Together with MaxDPI they
+	// determine the resolution at which the image is stored.
+	Width, Height Unit
+
+	// MaxDPI is the maximum resolution of the stored image in pixels per
+	// inch.  Images with a higher resolution at the size given by Width and
+	// Height are downsampled.  If MaxDPI, Width or Height is zero, the
+	// image is stored at its original resolution.
+	MaxDPI float32
+
+	// Filter is the filter used for downsampling.
+	Filter ResampleFilter
+
+	// JPEGQuality, if positive, stores the image with lossy JPEG
+	// compression at the given quality from 1 to 100.  Any transparency is
+	// stored losslessly in a separate soft mask.
+	JPEGQuality int
+
+	// ColorProfile, if set, is an ICC profile added with AddICCProfile
+	// that describes the colors of the image.  It is ignored if the
+	// profile does not have the same number of components as the image.
+	ColorProfile Reference
+
+	// Interpolate asks viewers to smooth the image when it is drawn at a
+	// higher resolution than it is stored in, instead of showing pixels as
+	// blocks.
+	Interpolate bool
+
+	// Decode, if set, maps the stored samples to color components.  It
+	// holds a minimum and maximum for each component of the stored image;
+	// for example, []float32{1, 0, 1, 0, 1, 0} inverts an RGB image.  See
+	// Section 8.9.5.2 of ISO 32000-1.
+	Decode []float32
+}
+
+// targetSize returns the number of pixels in each direction at which an
+// image of the given size is stored.  Both directions are scaled by the same
+// factor, so that the image keeps its aspect ratio even if it is drawn
+// distorted, and neither direction falls below MaxDPI.
+func (opts *ImageOptions) targetSize(w, h int) (int, int) {
+	if opts.MaxDPI <= 0 || opts.Width <= 0 || opts.Height <= 0 {
+		return w, h
+	}
+	maxW := float64(opts.Width/Inch) * float64(opts.MaxDPI)
+	maxH := float64(opts.Height/Inch) * float64(opts.MaxDPI)
+	scale := math.Max(maxW/float64(w), maxH/float64(h))
+	if scale >= 1 {
+		return w, h
+	}
+	size := func(n int) int {
+		if m := int(math.Ceil(float64(n) * scale)); m > 1 {
+			return m
+		}
+		return 1
+	}
+	return size(w), size(h)
+}
+
+// AddImageWithOptions is like AddImage, but stores the image as described by
+// opts.  A nil opts is the same as the zero value.
+func (doc *Document) AddImageWithOptions(img image.Image, opts *ImageOptions) Reference {
+	if opts == nil {
+		opts = new(ImageOptions)
+	}
+	bd := img.Bounds()
+	if w, h := opts.targetSize(bd.Dx(), bd.Dy()); w != bd.Dx() || h != bd.Dy() {
+		img = resample(img, w, h, opts.Filter)
+	}
+
+	var st *imageStream
+	if opts.JPEGQuality > 0 {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, unpremultiplied(img), &jpeg.Options{Quality: opts.JPEGQuality}); err == nil {
+			st, _ = newJPEGStream(buf.Bytes())
+		}
+	}
+	if st == nil {
+		st = newFlateImageStream(img)
+	}
+	st.ColorSpace = doc.withICCProfile(st.ColorSpace, opts.ColorProfile)
+	st.Interpolate = opts.Interpolate
+	if opts.Decode != nil {
+		st.Decode = opts.Decode
+	}
+	if !isOpaque(img) {
+		if key, ok := colorKey(img); ok && st.filter == streamFlateDecode {
+			st.Mask = []int{key, key}
+		} else {
+			st.SMask = doc.addImageStream(newSoftMaskStream(img))
+		}
+	}
+	return doc.addImageStream(st)
+}
+
+// unpremultiplied returns an opaque copy of an image with the colors of its
+// pixels not premultiplied by alpha.  Encoders that ignore alpha, like the
+// JPEG encoder, otherwise darken translucent pixels, which the soft mask then
+// makes translucent a second time.  Opaque images are returned unchanged.
+func unpremultiplied(img image.Image) image.Image {
+	if isOpaque(img) {
+		return img
+	}
+	bd := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bd.Dx(), bd.Dy()))
+	for y := bd.Min.Y; y < bd.Max.Y; y++ {
+		for x := bd.Min.X; x < bd.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			c.A = 0xff
+			dst.Set(x-bd.Min.X, y-bd.Min.Y, c)
+		}
+	}
+	return dst
+}
+
+// DrawImageWithOptions paints a raster image at the given location and
+// scaled to the given dimensions, like DrawImage.  The image is stored as
+// described by opts; if opts does not give the size of the image, the size
+// of rect on the page is used.
+func (canvas *Canvas) DrawImageWithOptions(img image.Image, rect Rectangle, opts *ImageOptions) {
+	o := ImageOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Width == 0 && o.Height == 0 {
+		o.Width, o.Height = canvas.pageSize(rect)
+	}
+	canvas.DrawImageReference(canvas.doc.AddImageWithOptions(img, &o), rect)
+}
+
+// An ImageFit selects how an image is fitted into a rectangle.
+type ImageFit int
+
+// Image fits
+const (
+	// StretchFit scales the image to fill the rectangle exactly, without
+	// preserving its aspect ratio.
+	StretchFit ImageFit = iota
+	// ContainFit scales the image to the largest size that fits inside the
+	// rectangle, preserving its aspect ratio.
+	ContainFit
+	// CoverFit scales the image to the smallest size that fills the
+	// rectangle, preserving its aspect ratio.  The parts of the image
+	// outside the rectangle are clipped.
+	CoverFit
+	// CenterFit draws the image unscaled at one unit per pixel.  Parts of
+	// the image outside the rectangle are clipped.
+	CenterFit
+)
+
+// DrawImageOptions controls how an image is placed in a rectangle.  The zero
+// value stretches the image to the rectangle, like DrawImageReference.
+type DrawImageOptions struct {
+	Fit ImageFit
+
+	// Rotation is the angle (in radians, counter-clockwise) by which the
+	// image is rotated about the center of the rectangle.  The image is
+	// fitted to the rectangle after rotation; when stretched, an image that
+	// is rotated by about a quarter turn takes its width from the height of
+	// the rectangle.
+	Rotation float32
+}
+
+// DrawImageReferenceWithOptions paints the raster image referenced in the
+// document into the given rectangle, placed as described by opts.  The image
+// is centered in the rectangle; its aspect ratio is that of the stored image.
+// A nil opts is the same as the zero value.
+func (canvas *Canvas) DrawImageReferenceWithOptions(ref Reference, rect Rectangle, opts *DrawImageOptions) {
+	o := DrawImageOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	var st *imageStream
+	if ref.Number > 0 && int(ref.Number) <= len(canvas.doc.objects) {
+		st, _ = canvas.doc.objects[ref.Number-1].(*imageStream)
+	}
+	if st == nil {
+		canvas.setErr(errors.New("pdf: reference does not refer to an image in the document"))
+		return
+	}
+	if o.Fit == StretchFit && o.Rotation == 0 {
+		canvas.DrawImageReference(ref, rect)
+		return
+	}
+
+	name := resourceName(&canvas.resources.XObject, anonymousImageFormat, ref)
+	canvas.Push()
+	if o.Fit == CoverFit || o.Fit == CenterFit {
+		var clip Path
+		clip.Rectangle(rect)
+		canvas.Clip(&clip)
+	}
+	w, h := imagePlacementSize(st.Width, st.Height, rect, o)
+	// Map the unit square to the image's size, rotated about its center,
+	// with the center at the center of the rectangle.
+	sin, cos := rotationSincos(o.Rotation)
+	a, b := float32(float64(w)*cos), float32(float64(w)*sin)
+	c, d := float32(0-float64(h)*sin), float32(float64(h)*cos)
+	cx, cy := float32(rect.Min.X+rect.Max.X)/2, float32(rect.Min.Y+rect.Max.Y)/2
+	canvas.Transform(a, b, c, d, cx-(a+c)/2, cy-(b+d)/2)
+	canvas.command("Do", name)
+	canvas.Pop()
+}
+
+// imagePlacementSize returns the size at which an image of w by h pixels is
+// drawn into rect, before it is rotated.
+func imagePlacementSize(w, h int, rect Rectangle, opts DrawImageOptions) (Unit, Unit) {
+	rw, rh := float64(rect.Dx()), float64(rect.Dy())
+	s, c := rotationSincos(opts.Rotation)
+	s, c = math.Abs(s), math.Abs(c)
+	switch opts.Fit {
+	case StretchFit:
+		if s > c {
+			return Unit(rh), Unit(rw)
+		}
+		return Unit(rw), Unit(rh)
+	case CenterFit:
+		return Unit(w), Unit(h)
+	}
+	// The bounding box of the rotated image.
+	bw, bh := float64(w)*c+float64(h)*s, float64(w)*s+float64(h)*c
+	scale := math.Min(rw/bw, rh/bh)
+	if opts.Fit == CoverFit {
+		scale = math.Max(rw/bw, rh/bh)
+	}
+	return Unit(float64(w) * scale), Unit(float64(h) * scale)
+}
+
+// rotationSincos returns the sine and cosine of an angle.  Since angles such
+// as math.Pi/2 are not exact as float32, values that are almost zero are
+// rounded to zero so that images rotated by quarter turns stay aligned.
+func rotationSincos(theta float32) (sin, cos float64) {
+	sin, cos = math.Sincos(float64(theta))
+	if math.Abs(sin) < 1e-6 {
+		sin = 0
+	}
+	if math.Abs(cos) < 1e-6 {
+		cos = 0
+	}
+	return sin, cos
+}
+
+// pageSize returns the lengths of the sides of a rectangle in the canvas's
+// current coordinate system when it is transformed into the default
+// coordinate system.
+func (canvas *Canvas) pageSize(rect Rectangle) (width, height Unit) {
+	m := canvas.Matrix()
+	length := func(dx, dy Unit) Unit {
+		x := m[0]*float32(dx) + m[2]*float32(dy)
+		y := m[1]*float32(dx) + m[3]*float32(dy)
+		return Unit(math.Hypot(float64(x), float64(y)))
+	}
+	return length(rect.Dx(), 0), length(0, rect.Dy())
+}
+
+// resample returns a copy of an image scaled to w by h pixels.  Grayscale
+// images stay grayscale; all other images are converted to RGBA.
+func resample(img image.Image, w, h int, filter ResampleFilter) image.Image {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		gray := image.NewGray(image.Rect(0, 0, w, h))
+		resampleInto(img, w, h, filter, func(x, y int, c [4]float64) {
+			gray.Pix[y*gray.Stride+x] = to8Bit(c[0])
+		})
+		return gray
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	resampleInto(img, w, h, filter, func(x, y int, c [4]float64) {
+		i := y*rgba.Stride + 4*x
+		for j := range c {
+			rgba.Pix[i+j] = to8Bit(c[j])
+		}
+	})
+	return rgba
+}
+
+// to8Bit converts a 16-bit color component into 8 bits.
+func to8Bit(v float64) uint8 {
+	return uint8(math.Min(255, v/0x101+0.5))
+}
+
+// resampleInto computes the pixels of an image scaled to w by h pixels and
+// passes their premultiplied 16-bit RGBA components to set.
+func resampleInto(img image.Image, w, h int, filter ResampleFilter, set func(x, y int, c [4]float64)) {
+	bd := img.Bounds()
+	sw, sh := bd.Dx(), bd.Dy()
+	at := func(x, y int) [4]float64 {
+		r, g, b, a := img.At(bd.Min.X+x, bd.Min.Y+y).RGBA()
+		return [4]float64{float64(r), float64(g), float64(b), float64(a)}
+	}
+	// coord maps the center of a destination pixel to source coordinates,
+	// with pixel centers at integer positions.
+	coord := func(i, n, sn int) (i0, i1 int, t float64) {
+		f := (float64(i)+0.5)*float64(sn)/float64(n) - 0.5
+		f = math.Max(0, math.Min(float64(sn-1), f))
+		i0 = int(f)
+		i1 = i0 + 1
+		if i1 >= sn {
+			i1 = sn - 1
+		}
+		return i0, i1, f - float64(i0)
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var c [4]float64
+			switch filter {
+			case NearestNeighborFilter:
+				c = at((2*x+1)*sw/(2*w), (2*y+1)*sh/(2*h))
+			case BilinearFilter:
+				x0, x1, tx := coord(x, w, sw)
+				y0, y1, ty := coord(y, h, sh)
+				c00, c10, c01, c11 := at(x0, y0), at(x1, y0), at(x0, y1), at(x1, y1)
+				for j := range c {
+					top := c00[j] + (c10[j]-c00[j])*tx
+					bottom := c01[j] + (c11[j]-c01[j])*tx
+					c[j] = top + (bottom-top)*ty
+				}
+			default:
+				x0, x1 := x*sw/w, (x+1)*sw/w
+				y0, y1 := y*sh/h, (y+1)*sh/h
+				if x1 <= x0 {
+					x1 = x0 + 1
+				}
+				if y1 <= y0 {
+					y1 = y0 + 1
+				}
+				for sy := y0; sy < y1; sy++ {
+					for sx := x0; sx < x1; sx++ {
+						p := at(sx, sy)
+						for j := range c {
+							c[j] += p[j]
+						}
+					}
+				}
+				n := float64((x1 - x0) * (y1 - y0))
+				for j := range c {
+					c[j] /= n
+				}
+			}
+			set(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+func TestImageOptionsTargetSize(t *testing.T) {
+	tests := []struct {
+		Options    ImageOptions
+		SrcW, SrcH int
+		W, H       int
+	}{
+		{ImageOptions{}, 3000, 2000, 3000, 2000},
+		{ImageOptions{Width: 3 * Cm, Height: 2 * Cm}, 3000, 2000, 3000, 2000},
+		// 3 cm at 300 DPI is 354.3 pixels.
+		{ImageOptions{Width: 3 * Cm, Height: 2 * Cm, MaxDPI: 300}, 3000, 2000, 355, 237},
+		// The width needs all pixels, so the height keeps them too.
+		{ImageOptions{Width: 20 * Inch, Height: 10 * Inch, MaxDPI: 150}, 3000, 2000, 3000, 2000},
+		// A wide image drawn into a square keeps its aspect ratio.
+		{ImageOptions{Width: Inch, Height: Inch, MaxDPI: 72}, 4000, 1000, 288, 72},
+	}
+	for i, tt := range tests {
+		if w, h := tt.Options.targetSize(tt.SrcW, tt.SrcH); w != tt.W || h != tt.H {
+			t.Errorf("%d. targetSize = %dx%d, expected %dx%d", i, w, h, tt.W, tt.H)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	copy(src.Pix, []byte{
+		0, 100, 200, 200,
+		100, 200, 0, 0,
+	})
+	tests := []struct {
+		Filter   ResampleFilter
+		Expected []byte
+	}{
+		{BoxFilter, []byte{100, 100}},
+		{NearestNeighborFilter, []byte{200, 0}},
+		{BilinearFilter, []byte{100, 100}},
+	}
+	for _, tt := range tests {
+		dst, ok := resample(src, 2, 1, tt.Filter).(*image.Gray)
+		if !ok {
+			t.Errorf("filter %d did not keep the image gray", tt.Filter)
+			continue
+		}
+		if string(dst.Pix) != string(tt.Expected) {
+			t.Errorf("filter %d: pixels = %v, expected %v", tt.Filter, dst.Pix, tt.Expected)
+		}
+	}
+
+	translucent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(translucent.Pix); i += 4 {
+		translucent.Pix[i], translucent.Pix[i+3] = 255, 128
+	}
+	rgba := resample(translucent, 2, 2, BoxFilter)
+	if c := color.NRGBAModel.Convert(rgba.At(1, 1)).(color.NRGBA); c.R != 255 || c.A != 128 {
+		t.Errorf("resampled color = %v, expected red at half opacity", c)
+	}
+}
+
+func TestAddImageWithOptions(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 600, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+
+	doc := New()
+	ref := doc.AddImageWithOptions(img, &ImageOptions{
+		Width:       2 * Inch,
+		Height:      1 * Inch,
+		MaxDPI:      100,
+		JPEGQuality: 80,
+	})
+	st := doc.objects[ref.Number-1].(*imageStream)
+	if st.Width != 200 || st.Height != 100 {
+		t.Errorf("size = %dx%d, expected 200x100", st.Width, st.Height)
+	}
+	if st.filter != streamDCTDecode {
+		t.Errorf("filter = %v, expected %v", st.filter, streamDCTDecode)
+	}
+	mask, ok := st.SMask.(Reference)
+	if !ok {
+		t.Fatal("translucent JPEG image has no soft mask")
+	}
+	if m := doc.objects[mask.Number-1].(*imageStream); m.filter != streamFlateDecode || m.Width != 200 {
+		t.Errorf("soft mask has filter %v and width %d, expected %v and 200", m.filter, m.Width, streamFlateDecode)
+	}
+}
+
+func TestAddTranslucentJPEG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+3] = 255, 128
+	}
+
+	doc := New()
+	ref := doc.AddImageWithOptions(img, &ImageOptions{JPEGQuality: 90})
+	st := doc.objects[ref.Number-1].(*imageStream)
+	decoded, err := jpeg.Decode(bytes.NewReader(st.Bytes()))
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	// The color must not be premultiplied by the alpha of the soft mask.
+	if r, g, b, _ := decoded.At(4, 4).RGBA(); r>>8 < 240 || g>>8 > 15 || b>>8 > 15 {
+		t.Errorf("color = %d, %d, %d; expected about 255, 0, 0", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestDrawImageWithOptions(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 1000, 1000))
+
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.Scale(2, 2)
+	canvas.Rotate(1)
+	// The image is 2 inches wide on the page.
+	canvas.DrawImageWithOptions(img, Rectangle{Point{0, 0}, Point{Inch, Inch}}, &ImageOptions{MaxDPI: 72})
+	canvas.Close()
+
+	for _, ref := range canvas.resources.XObject {
+		st := doc.objects[ref.(Reference).Number-1].(*imageStream)
+		if st.Width != 144 || st.Height != 144 {
+			t.Errorf("size = %dx%d, expected 144x144", st.Width, st.Height)
+		}
+	}
+}
+
+func TestImageStorageOptions(t *testing.T) {
+	doc := New()
+	ref := doc.AddImageWithOptions(image.NewGray(image.Rect(0, 0, 2, 2)), &ImageOptions{
+		Interpolate: true,
+		Decode:      []float32{1, 0},
+	})
+	st := doc.objects[ref.Number-1].(*imageStream)
+	if !st.Interpolate {
+		t.Error("image is not interpolated")
+	}
+	if len(st.Decode) != 2 || st.Decode[0] != 1 || st.Decode[1] != 0 {
+		t.Errorf("Decode = %v, expected [1 0]", st.Decode)
+	}
+}
+
+func TestImagePlacementSize(t *testing.T) {
+	rect := Rectangle{Point{0, 0}, Point{100, 50}}
+	tests := []struct {
+		Options DrawImageOptions
+		W, H    Unit
+	}{
+		{DrawImageOptions{}, 100, 50},
+		{DrawImageOptions{Rotation: math.Pi / 2}, 50, 100},
+		{DrawImageOptions{Fit: ContainFit}, 50, 50},
+		{DrawImageOptions{Fit: CoverFit}, 100, 100},
+		{DrawImageOptions{Fit: CenterFit}, 20, 20},
+		{DrawImageOptions{Fit: ContainFit, Rotation: math.Pi / 4}, 35.35534, 35.35534},
+	}
+	for _, tt := range tests {
+		w, h := imagePlacementSize(20, 20, rect, tt.Options)
+		if math.Abs(float64(w-tt.W)) > 1e-3 || math.Abs(float64(h-tt.H)) > 1e-3 {
+			t.Errorf("%+v: size = %vx%v, expected %vx%v", tt.Options, w, h, tt.W, tt.H)
+		}
+	}
+}
+
+const drawImageOptionsExpectedOutput = `q
+100.00000 0.00000 0.00000 50.00000 0.00000 25.00000 cm
+/__image0__ Do
+Q
+q
+0.00000 0.00000 100.00000 100.00000 re
+W n
+0.00000 200.00000 -100.00000 0.00000 100.00000 -50.00000 cm
+/__image0__ Do
+Q
+`
+
+func TestDrawImageReferenceWithOptions(t *testing.T) {
+	doc := New()
+	ref := doc.AddImage(image.NewGray(image.Rect(0, 0, 200, 100)))
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	rect := Rectangle{Point{0, 0}, Point{100, 100}}
+	canvas.DrawImageReferenceWithOptions(ref, rect, &DrawImageOptions{Fit: ContainFit})
+	canvas.DrawImageReferenceWithOptions(ref, rect, &DrawImageOptions{Fit: CoverFit, Rotation: math.Pi / 2})
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != drawImageOptionsExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, drawImageOptionsExpectedOutput)
+	}
+}
+
+func TestDrawDownsampledImageReferenceWithOptions(t *testing.T) {
+	doc := New()
+	ref := doc.AddImageWithOptions(image.NewGray(image.Rect(0, 0, 4000, 1000)), &ImageOptions{
+		Width:  Inch,
+		Height: Inch,
+		MaxDPI: 72,
+	})
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawImageReferenceWithOptions(ref, Rectangle{Point{0, 0}, Point{72, 72}}, &DrawImageOptions{Fit: ContainFit})
+	canvas.Close()
+
+	const expected = "q\n72.00000 0.00000 0.00000 18.00000 0.00000 27.00000 cm\n/__image0__ Do\nQ\n"
+	if output := canvasOutput(t, canvas); output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+}
+
+func TestDrawNonImageReferenceWithOptions(t *testing.T) {
+	doc := New()
+	template := doc.NewTemplate(Rectangle{Point{0, 0}, Point{10, 10}})
+	template.Close()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawImageReferenceWithOptions(template.Reference(), Rectangle{Point{0, 0}, Point{72, 72}}, nil)
+	if canvas.Err() == nil {
+		t.Error("drawing a template as an image did not set an error")
+	}
+}
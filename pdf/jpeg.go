@@ -0,0 +1,137 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const deviceCMYKColorSpace name = "DeviceCMYK"
+
+// JPEG markers
+const (
+	jpegSOI   = 0xd8
+	jpegEOI   = 0xd9
+	jpegSOS   = 0xda
+	jpegAPP14 = 0xee
+)
+
+// jpegInfo holds the properties of a JPEG image needed to embed it.
+type jpegInfo struct {
+	width, height int
+	precision     int
+	components    int
+	// adobe is set if the image has an Adobe APP14 marker.  Adobe
+	// applications write CMYK JPEGs with inverted components.
+	adobe bool
+}
+
+var errNotJPEG = errors.New("pdf: not a JPEG image")
+
+// isJPEGSOF reports whether a marker starts a frame.  The markers 0xc4 (DHT),
+// 0xc8 (JPG) and 0xcc (DAC) lie in the same range but do not.
+func isJPEGSOF(marker byte) bool {
+	return marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc
+}
+
+// parseJPEG reads the header of a JPEG image up to its first frame.
+func parseJPEG(data []byte) (jpegInfo, error) {
+	var info jpegInfo
+	if len(data) < 2 || data[0] != 0xff || data[1] != jpegSOI {
+		return info, errNotJPEG
+	}
+	for i := 2; ; {
+		// Markers start with 0xff, which may be repeated as fill bytes.
+		start := i
+		for i < len(data) && data[i] == 0xff {
+			i++
+		}
+		if i == start || i >= len(data) {
+			return info, errNotJPEG
+		}
+		marker := data[i]
+		i++
+		if marker == jpegEOI || marker == jpegSOS {
+			return info, errors.New("pdf: JPEG image has no frame header")
+		}
+		if marker >= 0xd0 && marker <= 0xd7 {
+			// Restart markers have no payload.
+			continue
+		}
+		if i+2 > len(data) {
+			return info, errNotJPEG
+		}
+		n := int(binary.BigEndian.Uint16(data[i:]))
+		if n < 2 || i+n > len(data) {
+			return info, errNotJPEG
+		}
+		segment := data[i+2 : i+n]
+		i += n
+
+		switch {
+		case marker == jpegAPP14:
+			if bytes.HasPrefix(segment, []byte("Adobe")) {
+				info.adobe = true
+			}
+		case isJPEGSOF(marker):
+			if len(segment) < 6 {
+				return info, errNotJPEG
+			}
+			info.precision = int(segment[0])
+			info.height = int(binary.BigEndian.Uint16(segment[1:]))
+			info.width = int(binary.BigEndian.Uint16(segment[3:]))
+			info.components = int(segment[5])
+			return info, nil
+		}
+	}
+}
+
+// AddJPEG adds a JPEG image to the document without decoding it and returns
+// its PDF file reference.  The image is stored as is, so it is usually much
+// smaller than the same image added with AddImage.  Grayscale, RGB and CMYK
+// images are supported.
+func (doc *Document) AddJPEG(r io.Reader) (Reference, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Reference{}, err
+	}
+	st, err := newJPEGStream(data)
+	if err != nil {
+		return Reference{}, err
+	}
+	return doc.addImageStream(st), nil
+}
+
+// newJPEGStream returns a closed image stream that holds JPEG data.
+func newJPEGStream(data []byte) (*imageStream, error) {
+	info, err := parseJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	if info.precision != 8 {
+		return nil, fmt.Errorf("pdf: unsupported JPEG precision %d", info.precision)
+	}
+
+	st := newImageStream(streamDCTDecode, info.width, info.height)
+	switch info.components {
+	case 1:
+		st.ColorSpace = deviceGrayColorSpace
+	case 3:
+		st.ColorSpace = deviceRGBColorSpace
+	case 4:
+		st.ColorSpace = deviceCMYKColorSpace
+		if info.adobe {
+			st.Decode = []float32{1, 0, 1, 0, 1, 0, 1, 0}
+		}
+	default:
+		return nil, fmt.Errorf("pdf: unsupported number of JPEG components %d", info.components)
+	}
+	st.Write(data)
+	st.Close()
+	return st, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func TestAddJPEG(t *testing.T) {
+	tests := []struct {
+		Image      image.Image
+		ColorSpace name
+	}{
+		{image.NewGray(image.Rect(0, 0, 7, 5)), deviceGrayColorSpace},
+		{image.NewRGBA(image.Rect(0, 0, 7, 5)), deviceRGBColorSpace},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, tt.Image, nil); err != nil {
+			t.Fatalf("%d. jpeg.Encode: %v", i, err)
+		}
+		doc := New()
+		ref, err := doc.AddJPEG(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Errorf("%d. AddJPEG: %v", i, err)
+			continue
+		}
+		st := doc.objects[ref.Number-1].(*imageStream)
+		if st.Width != 7 || st.Height != 5 {
+			t.Errorf("%d. size = %dx%d, expected 7x5", i, st.Width, st.Height)
+		}
+		if st.ColorSpace != tt.ColorSpace {
+			t.Errorf("%d. ColorSpace = %v, expected %v", i, st.ColorSpace, tt.ColorSpace)
+		}
+		if st.filter != streamDCTDecode {
+			t.Errorf("%d. filter = %v, expected %v", i, st.filter, streamDCTDecode)
+		}
+		if !bytes.Equal(st.Bytes(), buf.Bytes()) {
+			t.Errorf("%d. image data was not copied unchanged", i)
+		}
+	}
+}
+
+// adobeCMYKHeader is the start of a CMYK JPEG written by an Adobe
+// application: SOI, an APP14 segment and a baseline frame header for a 16x8
+// image with four components.
+var adobeCMYKHeader = []byte{
+	0xff, 0xd8,
+	0xff, 0xee, 0x00, 0x0e, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x02,
+	0xff, 0xc0, 0x00, 0x14, 0x08, 0x00, 0x08, 0x00, 0x10, 0x04,
+	0x01, 0x11, 0x00, 0x02, 0x11, 0x00, 0x03, 0x11, 0x00, 0x04, 0x11, 0x00,
+}
+
+func TestAddJPEGAdobeCMYK(t *testing.T) {
+	doc := New()
+	ref, err := doc.AddJPEG(bytes.NewReader(adobeCMYKHeader))
+	if err != nil {
+		t.Fatalf("AddJPEG: %v", err)
+	}
+	st := doc.objects[ref.Number-1].(*imageStream)
+	if st.Width != 16 || st.Height != 8 {
+		t.Errorf("size = %dx%d, expected 16x8", st.Width, st.Height)
+	}
+	if st.ColorSpace != deviceCMYKColorSpace {
+		t.Errorf("ColorSpace = %v, expected %v", st.ColorSpace, deviceCMYKColorSpace)
+	}
+	const expected = "[ 1.00000 0.00000 1.00000 0.00000 1.00000 0.00000 1.00000 0.00000 ]"
+	if output, _ := marshal(nil, st.Decode); string(output) != expected {
+		t.Errorf("Decode was %q, expected %q", output, expected)
+	}
+}
+
+func TestAddJPEGInvalid(t *testing.T) {
+	doc := New()
+	if _, err := doc.AddJPEG(bytes.NewReader([]byte("GIF89a"))); err == nil {
+		t.Error("AddJPEG accepted a GIF")
+	}
+	if _, err := doc.AddJPEG(bytes.NewReader(adobeCMYKHeader[:20])); err == nil {
+		t.Error("AddJPEG accepted a truncated JPEG")
+	}
+}
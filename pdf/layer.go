@@ -0,0 +1,96 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"errors"
+)
+
+// ocProperties is the optional content properties dictionary of a document.
+// See Section 8.11.4 of ISO 32000-1.
+type ocProperties struct {
+	OCGs []Reference
+	D    ocConfig
+}
+
+// ocConfig is an optional content configuration dictionary.  Groups are on
+// unless they are listed in OFF.
+type ocConfig struct {
+	Order []Reference
+	OFF   []Reference `pdf:",omitempty"`
+}
+
+// optionalContentGroup is an optional content group dictionary.
+type optionalContentGroup struct {
+	Type name
+	Name string
+}
+
+// AddLayer adds an optional content group with the given title to the
+// document.  Viewers list the group in their layers panel, where it can be
+// shown or hidden; visible sets whether it is shown when the document is
+// opened.  Content is assigned to the layer with Canvas.BeginLayer.
+func (doc *Document) AddLayer(title string, visible bool) Reference {
+	ref := doc.add(optionalContentGroup{
+		Type: ocgType,
+		Name: title,
+	})
+	if doc.catalog.OCProperties == nil {
+		doc.catalog.OCProperties = new(ocProperties)
+	}
+	oc := doc.catalog.OCProperties
+	oc.OCGs = append(oc.OCGs, ref)
+	oc.D.Order = append(oc.D.Order, ref)
+	if !visible {
+		oc.D.OFF = append(oc.D.OFF, ref)
+	}
+	return ref
+}
+
+const anonymousPropertiesFormat = "__oc%d__"
+
+// optionalContentTag is the marked content tag for optional content.
+const optionalContentTag name = "OC"
+
+// BeginLayer begins a sequence of content that belongs to a layer added with
+// AddLayer.  The content is only shown when the layer is visible.  Every
+// BeginLayer must be matched by a call to EndLayer.
+func (canvas *Canvas) BeginLayer(layer Reference) {
+	n := resourceName(&canvas.resources.Properties, anonymousPropertiesFormat, layer)
+	canvas.command("BDC", optionalContentTag, n)
+	canvas.markedContent++
+}
+
+// EndLayer ends a sequence of content begun with BeginLayer.
+func (canvas *Canvas) EndLayer() {
+	canvas.EndMarkedContent()
+}
+
+// BeginMarkedContent begins a marked content sequence with the given tag,
+// which identifies the role of the content to consumers such as
+// accessibility tools.  If props is not nil, it is written as the sequence's
+// property list; its values must be direct objects such as strings and
+// numbers.  Every BeginMarkedContent must be matched by a call to
+// EndMarkedContent.  See Section 14.6 of ISO 32000-1.
+func (canvas *Canvas) BeginMarkedContent(tag string, props map[string]interface{}) {
+	if props == nil {
+		canvas.command("BMC", name(tag))
+	} else {
+		dict := make(map[name]interface{}, len(props))
+		for k, v := range props {
+			dict[name(k)] = v
+		}
+		canvas.command("BDC", name(tag), dict)
+	}
+	canvas.markedContent++
+}
+
+// EndMarkedContent ends the most recently begun marked content sequence.
+func (canvas *Canvas) EndMarkedContent() {
+	if canvas.markedContent == 0 {
+		canvas.setErr(errors.New("pdf: EndMarkedContent without matching BeginMarkedContent"))
+		return
+	}
+	canvas.command("EMC")
+	canvas.markedContent--
+}
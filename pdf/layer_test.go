@@ -0,0 +1,96 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+const layerExpectedOutput = `/OC /__oc0__ BDC
+/OC /__oc1__ BDC
+EMC
+EMC
+/OC /__oc0__ BDC
+EMC
+/Artifact BMC
+EMC
+/Span << /ActualText (fi) >> BDC
+EMC
+`
+
+func TestLayers(t *testing.T) {
+	doc := New()
+	grid := doc.AddLayer("Grid", false)
+	dims := doc.AddLayer("Dimensions", true)
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.BeginLayer(grid)
+	canvas.BeginLayer(dims)
+	canvas.EndLayer()
+	canvas.EndLayer()
+	canvas.BeginLayer(grid)
+	canvas.EndLayer()
+	canvas.BeginMarkedContent("Artifact", nil)
+	canvas.EndMarkedContent()
+	canvas.BeginMarkedContent("Span", map[string]interface{}{"ActualText": "fi"})
+	canvas.EndMarkedContent()
+	if err := canvas.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if output := canvasOutput(t, canvas); output != layerExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, layerExpectedOutput)
+	}
+	if len(canvas.resources.Properties) != 2 {
+		t.Errorf("page has %d properties resources, expected 2", len(canvas.resources.Properties))
+	}
+
+	expected := fmt.Sprintf("<< /OCGs [ %d 0 R %d 0 R ] /D << /Order [ %d 0 R %d 0 R ] /OFF [ %d 0 R ] >> >>",
+		grid.Number, dims.Number, grid.Number, dims.Number, grid.Number)
+	if output, err := marshal(nil, doc.catalog.OCProperties); err != nil {
+		t.Errorf("marshal: %v", err)
+	} else if string(output) != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+}
+
+func TestUnbalancedMarkedContent(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.BeginMarkedContent("Artifact", nil)
+	if err := canvas.Close(); err == nil {
+		t.Error("Close succeeded with an open marked content sequence")
+	}
+
+	doc = New()
+	canvas = doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.BeginMarkedContent("Artifact", nil)
+	if err := doc.Encode(ioutil.Discard); err == nil {
+		t.Error("Encode succeeded with an open marked content sequence on an unclosed canvas")
+	}
+
+	canvas = doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.EndLayer()
+	if err := canvas.Close(); err == nil {
+		t.Error("Close succeeded after EndLayer without BeginLayer")
+	}
+}
+
+func TestMarkedContentPropertyOrder(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.BeginMarkedContent("Span", map[string]interface{}{
+		"Lang":       "en",
+		"ActualText": "fi",
+		"E":          "file",
+		"Alt":        "ligature",
+	})
+	canvas.EndMarkedContent()
+	canvas.Close()
+
+	const expected = "/Span << /ActualText (fi) /Alt (ligature) /E (file) /Lang (en) >> BDC\nEMC\n"
+	if output := canvasOutput(t, canvas); output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -54,6 +55,9 @@ func (state *marshalState) marshalValue(v reflect.Value) error {
 	}
 
 	switch v.Kind() {
+	case reflect.Bool:
+		state.writeString(strconv.FormatBool(v.Bool()))
+		return nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		state.writeString(strconv.FormatInt(v.Int(), 10))
 		return nil
@@ -104,7 +108,7 @@ func quote(s string) string {
 				escaped = escaped + string([]byte{b})
 			}
 		}
-		encoded = encoded + fmt.Sprintf("%2X", b)
+		encoded = encoded + fmt.Sprintf("%02X", b)
 	}
 	if len(encoded) < len(escaped) {
 		return "<" + encoded + ">"
@@ -129,9 +133,14 @@ func (state *marshalState) marshalDictionary(v reflect.Value) error {
 		return errors.New("pdf: cannot marshal dictionary with key type: " + v.Type().Key().String())
 	}
 
+	// Sort the keys so that the output does not depend on map order.
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
 	state.writeString("<< ")
-	for _, k := range v.MapKeys() {
-		state.marshalKeyValue(k.Interface().(name), v.MapIndex(k))
+	for _, k := range keys {
+		if err := state.marshalKeyValue(k.Interface().(name), v.MapIndex(k)); err != nil {
+			return err
+		}
 	}
 	state.writeString(">>")
 	return nil
@@ -165,7 +174,9 @@ func (state *marshalState) marshalStruct(v reflect.Value) error {
 			continue
 		}
 
-		state.marshalKeyValue(name(tag), fieldValue)
+		if err := state.marshalKeyValue(name(tag), fieldValue); err != nil {
+			return err
+		}
 	}
 	state.writeString(">>")
 	return nil
@@ -21,11 +21,14 @@ type fooStruct struct {
 
 var marshalTests = []marshalTest{
 	{nil, "null"},
+	{true, "true"},
+	{false, "false"},
 	{"", "()"},
 	{"This is a string", "(This is a string)"},
 	{"Strings may contain newlines\nand such.", "(Strings may contain newlines\nand such.)"},
 	{"Escape (this).", `(Escape \(this\).)`},
 	{"\xE5\xE4\xF6.", "<E5E4F62E>"},
+	{"\x00\x01\x80\x0F", "<0001800F>"},
 	{int(123), "123"},
 	{int(-321), "-321"},
 	{float64(-3.141599), "-3.14160"},
@@ -0,0 +1,273 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// A MeshVertex is a point in a mesh shading together with its color.
+type MeshVertex struct {
+	Point Point
+	Color RGB
+}
+
+// A MeshTriangle is a triangle in a free-form triangle mesh.  The colors of
+// the vertices are interpolated across the triangle.
+type MeshTriangle [3]MeshVertex
+
+// A CoonsPatch is a patch bounded by four cubic Bézier curves.  Points lists
+// the boundary's twelve control points counter-clockwise, starting at a
+// corner: the first curve is Points[0:4], the second Points[3:7], the third
+// Points[6:10] and the fourth Points[9:12] followed by Points[0].  Colors
+// holds the colors of the corners Points[0], Points[3], Points[6] and
+// Points[9], in that order.
+type CoonsPatch struct {
+	Points [12]Point
+	Colors [4]RGB
+}
+
+// A TensorPatch is a Coons patch with four additional interior control
+// points.  Points holds the twelve boundary points in the same order as a
+// CoonsPatch, followed by the interior points p11, p12, p22 and p21 as
+// described in Section 8.7.4.5.8 of ISO 32000-1.  Colors holds the colors of
+// the corners Points[0], Points[3], Points[6] and Points[9], in that order.
+type TensorPatch struct {
+	Points [16]Point
+	Colors [4]RGB
+}
+
+// Mesh shading types
+const (
+	freeFormShadingType = 4
+	latticeShadingType  = 5
+	coonsShadingType    = 6
+	tensorShadingType   = 7
+)
+
+const (
+	meshBitsPerCoordinate = 32
+	meshBitsPerComponent  = 16
+	meshBitsPerFlag       = 8
+)
+
+// meshShading is a shading whose geometry is stored in a stream.  See
+// Sections 8.7.4.5.5 through 8.7.4.5.8 of ISO 32000-1.
+type meshShading struct {
+	*stream
+	ShadingType    int
+	VerticesPerRow int
+	Decode         []float32
+}
+
+type meshShadingInfo struct {
+	Length            int
+	Filter            name `pdf:",omitempty"`
+	ShadingType       int
+	ColorSpace        name
+	BitsPerCoordinate int
+	BitsPerComponent  int
+	BitsPerFlag       int `pdf:",omitempty"`
+	VerticesPerRow    int `pdf:",omitempty"`
+	Decode            []float32
+}
+
+func (st *meshShading) marshalPDF(dst []byte) ([]byte, error) {
+	info := meshShadingInfo{
+		Length:            st.Len(),
+		Filter:            st.filter,
+		ShadingType:       st.ShadingType,
+		ColorSpace:        deviceRGBColorSpace,
+		BitsPerCoordinate: meshBitsPerCoordinate,
+		BitsPerComponent:  meshBitsPerComponent,
+		VerticesPerRow:    st.VerticesPerRow,
+		Decode:            st.Decode,
+	}
+	if st.ShadingType != latticeShadingType {
+		info.BitsPerFlag = meshBitsPerFlag
+	}
+	return marshalStream(dst, info, st.Bytes())
+}
+
+var errEmptyMesh = errors.New("pdf: mesh has no vertices")
+
+// meshEncoder writes mesh data with coordinates scaled to the mesh's
+// bounding box.
+type meshEncoder struct {
+	w      io.Writer
+	bounds Rectangle
+	buf    []byte
+}
+
+func newMeshEncoder(w io.Writer, points []Point) *meshEncoder {
+	b := Rectangle{points[0], points[0]}
+	for _, pt := range points[1:] {
+		b.Min.X = Unit(math.Min(float64(b.Min.X), float64(pt.X)))
+		b.Min.Y = Unit(math.Min(float64(b.Min.Y), float64(pt.Y)))
+		b.Max.X = Unit(math.Max(float64(b.Max.X), float64(pt.X)))
+		b.Max.Y = Unit(math.Max(float64(b.Max.Y), float64(pt.Y)))
+	}
+	// Avoid an empty decode range for degenerate meshes.
+	if b.Dx() == 0 {
+		b.Max.X++
+	}
+	if b.Dy() == 0 {
+		b.Max.Y++
+	}
+	return &meshEncoder{w: w, bounds: b}
+}
+
+// decode returns the Decode array that maps the encoded values back into
+// the mesh's coordinates and colors.
+func (enc *meshEncoder) decode() []float32 {
+	return []float32{
+		float32(enc.bounds.Min.X), float32(enc.bounds.Max.X),
+		float32(enc.bounds.Min.Y), float32(enc.bounds.Max.Y),
+		0, 1, 0, 1, 0, 1,
+	}
+}
+
+func (enc *meshEncoder) flag(f byte) {
+	enc.buf = append(enc.buf, f)
+}
+
+func (enc *meshEncoder) point(pt Point) {
+	const max = 1<<meshBitsPerCoordinate - 1
+	x := float64(pt.X-enc.bounds.Min.X) / float64(enc.bounds.Dx())
+	y := float64(pt.Y-enc.bounds.Min.Y) / float64(enc.bounds.Dy())
+	enc.buf = binary.BigEndian.AppendUint32(enc.buf, uint32(math.Round(x*max)))
+	enc.buf = binary.BigEndian.AppendUint32(enc.buf, uint32(math.Round(y*max)))
+}
+
+func (enc *meshEncoder) color(c RGB) {
+	const max = 1<<meshBitsPerComponent - 1
+	for _, v := range c.components() {
+		v = float32(math.Max(0, math.Min(1, float64(v))))
+		enc.buf = binary.BigEndian.AppendUint16(enc.buf, uint16(math.Round(float64(v)*max)))
+	}
+}
+
+// flush writes the buffered data.
+func (enc *meshEncoder) flush() error {
+	_, err := enc.w.Write(enc.buf)
+	enc.buf = enc.buf[:0]
+	return err
+}
+
+// addMesh adds a mesh shading to the document.  The encode function is called
+// to write the mesh data once the bounds of points are known.
+func (doc *Document) addMesh(shadingType, verticesPerRow int, points []Point, encode func(*meshEncoder)) Reference {
+	st := &meshShading{
+		stream:         newStream(streamFlateDecode),
+		ShadingType:    shadingType,
+		VerticesPerRow: verticesPerRow,
+	}
+	enc := newMeshEncoder(st, points)
+	encode(enc)
+	enc.flush()
+	st.Close()
+	st.Decode = enc.decode()
+	return doc.add(st)
+}
+
+// AddTriangleMesh adds a free-form Gouraud-shaded triangle mesh to the
+// document.  The returned reference can be painted with Canvas.PaintShading
+// or used as a fill with AddShadingPattern.
+func (doc *Document) AddTriangleMesh(triangles []MeshTriangle) (Reference, error) {
+	if len(triangles) == 0 {
+		return Reference{}, errEmptyMesh
+	}
+	points := make([]Point, 0, 3*len(triangles))
+	for _, t := range triangles {
+		points = append(points, t[0].Point, t[1].Point, t[2].Point)
+	}
+	return doc.addMesh(freeFormShadingType, 0, points, func(enc *meshEncoder) {
+		for _, t := range triangles {
+			for _, v := range t {
+				// Flag 0 starts a new triangle for every vertex triple.
+				enc.flag(0)
+				enc.point(v.Point)
+				enc.color(v.Color)
+			}
+		}
+	}), nil
+}
+
+// AddLatticeMesh adds a lattice-form Gouraud-shaded triangle mesh to the
+// document.  The vertices form a grid of rows that all have the same length;
+// each cell of the grid is divided into two triangles.  The returned
+// reference can be painted with Canvas.PaintShading or used as a fill with
+// AddShadingPattern.
+func (doc *Document) AddLatticeMesh(rows [][]MeshVertex) (Reference, error) {
+	if len(rows) < 2 || len(rows[0]) < 2 {
+		return Reference{}, errors.New("pdf: lattice mesh needs at least two rows of two vertices")
+	}
+	var points []Point
+	for _, row := range rows {
+		if len(row) != len(rows[0]) {
+			return Reference{}, errors.New("pdf: lattice mesh rows differ in length")
+		}
+		for _, v := range row {
+			points = append(points, v.Point)
+		}
+	}
+	return doc.addMesh(latticeShadingType, len(rows[0]), points, func(enc *meshEncoder) {
+		for _, row := range rows {
+			for _, v := range row {
+				enc.point(v.Point)
+				enc.color(v.Color)
+			}
+		}
+	}), nil
+}
+
+// AddCoonsPatchMesh adds a Coons patch mesh to the document.  The returned
+// reference can be painted with Canvas.PaintShading or used as a fill with
+// AddShadingPattern.
+func (doc *Document) AddCoonsPatchMesh(patches []CoonsPatch) (Reference, error) {
+	if len(patches) == 0 {
+		return Reference{}, errEmptyMesh
+	}
+	points := make([]Point, 0, 12*len(patches))
+	for _, p := range patches {
+		points = append(points, p.Points[:]...)
+	}
+	return doc.addMesh(coonsShadingType, 0, points, func(enc *meshEncoder) {
+		for _, p := range patches {
+			enc.flag(0)
+			for _, pt := range p.Points {
+				enc.point(pt)
+			}
+			for _, c := range p.Colors {
+				enc.color(c)
+			}
+		}
+	}), nil
+}
+
+// AddTensorPatchMesh adds a tensor-product patch mesh to the document.  The
+// returned reference can be painted with Canvas.PaintShading or used as a
+// fill with AddShadingPattern.
+func (doc *Document) AddTensorPatchMesh(patches []TensorPatch) (Reference, error) {
+	if len(patches) == 0 {
+		return Reference{}, errEmptyMesh
+	}
+	points := make([]Point, 0, 16*len(patches))
+	for _, p := range patches {
+		points = append(points, p.Points[:]...)
+	}
+	return doc.addMesh(tensorShadingType, 0, points, func(enc *meshEncoder) {
+		for _, p := range patches {
+			enc.flag(0)
+			for _, pt := range p.Points {
+				enc.point(pt)
+			}
+			for _, c := range p.Colors {
+				enc.color(c)
+			}
+		}
+	}), nil
+}
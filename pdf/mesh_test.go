@@ -0,0 +1,79 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestTriangleMesh(t *testing.T) {
+	doc := New()
+	ref, err := doc.AddTriangleMesh([]MeshTriangle{{
+		{Point{0, 0}, RGB{1, 0, 0}},
+		{Point{100, 0}, RGB{0, 1, 0}},
+		{Point{50, 50}, RGB{0, 0, 1}},
+	}})
+	if err != nil {
+		t.Fatalf("AddTriangleMesh error: %v", err)
+	}
+	st := doc.objects[ref.Number-1].(*meshShading)
+
+	expectedDecode := []float32{0, 100, 0, 50, 0, 1, 0, 1, 0, 1}
+	if !reflect.DeepEqual(st.Decode, expectedDecode) {
+		t.Errorf("Decode = %v, expected %v", st.Decode, expectedDecode)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(st.Bytes()))
+	if err != nil {
+		t.Fatalf("zlib.NewReader: %v", err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	expected := []byte{
+		0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+		0, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00,
+		0, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff,
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("mesh data = %x, expected %x", data, expected)
+	}
+}
+
+func TestLatticeMesh(t *testing.T) {
+	doc := New()
+	if _, err := doc.AddLatticeMesh([][]MeshVertex{{{}, {}}, {{}}}); err == nil {
+		t.Error("AddLatticeMesh with uneven rows did not return an error")
+	}
+	ref, err := doc.AddLatticeMesh([][]MeshVertex{
+		{{Point{0, 0}, RGB{}}, {Point{10, 0}, RGB{}}, {Point{20, 0}, RGB{}}},
+		{{Point{0, 10}, RGB{}}, {Point{10, 10}, RGB{}}, {Point{20, 10}, RGB{}}},
+	})
+	if err != nil {
+		t.Fatalf("AddLatticeMesh error: %v", err)
+	}
+	b, err := marshal(nil, doc.objects[ref.Number-1])
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	const prefix = "<< /Length "
+	const expected = " /Filter /FlateDecode /ShadingType 5 /ColorSpace /DeviceRGB /BitsPerCoordinate 32 /BitsPerComponent 16 /VerticesPerRow 3 /Decode [ 0.00000 20.00000 0.00000 10.00000 0.00000 1.00000 0.00000 1.00000 0.00000 1.00000 ] >>"
+	if i := bytes.Index(b, []byte(">>")); !bytes.HasPrefix(b, []byte(prefix)) || i < 0 || !bytes.HasSuffix(b[:i+2], []byte(expected)) {
+		t.Errorf("lattice mesh dictionary is %q", b)
+	}
+}
+
+func TestEmptyMesh(t *testing.T) {
+	doc := New()
+	if _, err := doc.AddTriangleMesh(nil); err == nil {
+		t.Error("AddTriangleMesh(nil) did not return an error")
+	}
+	if _, err := doc.AddCoonsPatchMesh(nil); err == nil {
+		t.Error("AddCoonsPatchMesh(nil) did not return an error")
+	}
+	if _, err := doc.AddTensorPatchMesh(nil); err == nil {
+		t.Error("AddTensorPatchMesh(nil) did not return an error")
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"io"
+	"math"
+)
+
+// SegmentType identifies the kind of a path segment.
+type SegmentType int
+
+// Path segment types
+const (
+	// MoveSegment begins a new subpath at Points[0].
+	MoveSegment SegmentType = iota
+	// LineSegment is a straight line from the current point to Points[0].
+	LineSegment
+	// CurveSegment is a cubic Bezier curve from the current point to
+	// Points[2], with the control points Points[0] and Points[1].
+	CurveSegment
+	// RectangleSegment is a complete rectangular subpath that starts at the
+	// corner Points[0] and has the opposite corner Points[1].
+	RectangleSegment
+	// CloseSegment is a straight line from the current point back to the
+	// start of the subpath, which closes the subpath.
+	CloseSegment
+)
+
+// A Segment is a single element of a path.  The number of points used
+// depends on the segment's type.
+type Segment struct {
+	Type   SegmentType
+	Points [3]Point
+}
+
+// Path is a shape that can be painted on a canvas.  The zero value is an empty
+// path.  Painting a path does not modify it, so the same path can be painted
+// any number of times.
+type Path struct {
+	segments []Segment
+
+	// start is the first point of the current subpath and current is the
+	// current point.  Both are only valid if hasCurrent is true.
+	start, current Point
+	hasCurrent     bool
+}
+
+// add appends a segment to the path and updates the current point.
+func (path *Path) add(seg Segment) {
+	path.segments = append(path.segments, seg)
+	switch seg.Type {
+	case MoveSegment:
+		path.start, path.current, path.hasCurrent = seg.Points[0], seg.Points[0], true
+	case LineSegment:
+		path.current, path.hasCurrent = seg.Points[0], true
+	case CurveSegment:
+		path.current, path.hasCurrent = seg.Points[2], true
+	case RectangleSegment:
+		path.start, path.current, path.hasCurrent = seg.Points[0], seg.Points[0], true
+	case CloseSegment:
+		path.current = path.start
+	}
+}
+
+// Move begins a new subpath by moving the current point to the given location.
+func (path *Path) Move(pt Point) {
+	path.add(Segment{Type: MoveSegment, Points: [3]Point{pt}})
+}
+
+// Line appends a line segment from the current point to the given location.
+func (path *Path) Line(pt Point) {
+	path.add(Segment{Type: LineSegment, Points: [3]Point{pt}})
+}
+
+// Curve appends a cubic Bezier curve to the path.
+func (path *Path) Curve(pt1, pt2, pt3 Point) {
+	path.add(Segment{Type: CurveSegment, Points: [3]Point{pt1, pt2, pt3}})
+}
+
+// Rectangle appends a complete rectangle to the path.
+func (path *Path) Rectangle(rect Rectangle) {
+	path.add(Segment{Type: RectangleSegment, Points: [3]Point{rect.Min, rect.Max}})
+}
+
+// Close appends a line segment from the current point to the starting point of
+// the subpath.
+func (path *Path) Close() {
+	path.add(Segment{Type: CloseSegment})
+}
+
+// Segments returns a copy of the path's segments.
+func (path *Path) Segments() []Segment {
+	return append([]Segment(nil), path.segments...)
+}
+
+// Len returns the number of segments in the path.
+func (path *Path) Len() int {
+	return len(path.segments)
+}
+
+// Append appends the segments of another path to the path.
+func (path *Path) Append(other *Path) {
+	for _, seg := range other.segments {
+		path.add(seg)
+	}
+}
+
+// Bounds returns the smallest rectangle that contains the path.  The bounds
+// of curves are exact, not just the bounds of their control points.  An
+// empty path has empty bounds.
+func (path *Path) Bounds() Rectangle {
+	var b Rectangle
+	first := true
+	extend := func(pt Point) {
+		if first {
+			b = Rectangle{pt, pt}
+			first = false
+			return
+		}
+		b.Min.X = minUnit(b.Min.X, pt.X)
+		b.Min.Y = minUnit(b.Min.Y, pt.Y)
+		b.Max.X = maxUnit(b.Max.X, pt.X)
+		b.Max.Y = maxUnit(b.Max.Y, pt.Y)
+	}
+	var cur, start Point
+	for _, seg := range path.segments {
+		switch seg.Type {
+		case MoveSegment:
+			cur, start = seg.Points[0], seg.Points[0]
+			extend(cur)
+		case LineSegment:
+			cur = seg.Points[0]
+			extend(cur)
+		case CurveSegment:
+			c := [4]Point{cur, seg.Points[0], seg.Points[1], seg.Points[2]}
+			for _, t := range curveExtrema(c) {
+				extend(bezierAt(c, t))
+			}
+			cur = seg.Points[2]
+			extend(cur)
+		case RectangleSegment:
+			extend(seg.Points[0])
+			extend(seg.Points[1])
+			cur, start = seg.Points[0], seg.Points[0]
+		case CloseSegment:
+			// Closing returns to a point already included.
+			cur = start
+		}
+	}
+	return b
+}
+
+func minUnit(a, b Unit) Unit {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxUnit(a, b Unit) Unit {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// bezierAt evaluates a cubic Bezier curve at t.
+func bezierAt(c [4]Point, t float64) Point {
+	mt := 1 - t
+	a, b, cc, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+	return Point{
+		Unit(a*float64(c[0].X) + b*float64(c[1].X) + cc*float64(c[2].X) + d*float64(c[3].X)),
+		Unit(a*float64(c[0].Y) + b*float64(c[1].Y) + cc*float64(c[2].Y) + d*float64(c[3].Y)),
+	}
+}
+
+// curveExtrema returns the parameters in (0, 1) at which a cubic Bezier curve
+// has a horizontal or vertical tangent.
+func curveExtrema(c [4]Point) []float64 {
+	var ts []float64
+	for _, v := range [2][4]float64{
+		{float64(c[0].X), float64(c[1].X), float64(c[2].X), float64(c[3].X)},
+		{float64(c[0].Y), float64(c[1].Y), float64(c[2].Y), float64(c[3].Y)},
+	} {
+		// The derivative is proportional to a*t^2 + b*t + c.
+		a := -v[0] + 3*v[1] - 3*v[2] + v[3]
+		b := 2 * (v[0] - 2*v[1] + v[2])
+		c := v[1] - v[0]
+		const epsilon = 1e-12
+		if math.Abs(a) < epsilon {
+			if math.Abs(b) > epsilon {
+				ts = append(ts, -c/b)
+			}
+			continue
+		}
+		disc := b*b - 4*a*c
+		if disc < 0 {
+			continue
+		}
+		sq := math.Sqrt(disc)
+		ts = append(ts, (-b+sq)/(2*a), (-b-sq)/(2*a))
+	}
+	valid := ts[:0]
+	for _, t := range ts {
+		if t > 0 && t < 1 {
+			valid = append(valid, t)
+		}
+	}
+	return valid
+}
+
+// Transform applies a transformation matrix to every point in the path.
+// Rectangles are converted into lines unless m only scales and translates.
+func (path *Path) Transform(m Matrix) {
+	segs := path.segments
+	path.segments = make([]Segment, 0, len(segs))
+	path.hasCurrent = false
+	for _, seg := range segs {
+		if seg.Type == RectangleSegment && (m[1] != 0 || m[2] != 0) {
+			min, max := seg.Points[0], seg.Points[1]
+			path.Move(m.Apply(min))
+			path.Line(m.Apply(Point{max.X, min.Y}))
+			path.Line(m.Apply(max))
+			path.Line(m.Apply(Point{min.X, max.Y}))
+			path.Close()
+			continue
+		}
+		for i := range seg.Points {
+			seg.Points[i] = m.Apply(seg.Points[i])
+		}
+		path.add(seg)
+	}
+}
+
+// Reverse reverses the direction of every subpath in the path.  The subpaths
+// keep their order.
+func (path *Path) Reverse() {
+	segs := path.segments
+	path.segments = make([]Segment, 0, len(segs))
+	path.hasCurrent = false
+
+	var (
+		sub  []Segment // segments of the current subpath, after the move
+		from Point     // start point of the current subpath
+	)
+	flush := func(closed bool) {
+		if len(sub) == 0 {
+			return
+		}
+		// Points[i] is where segment i starts.
+		starts := make([]Point, len(sub))
+		p := from
+		for i, seg := range sub {
+			starts[i] = p
+			if seg.Type == CurveSegment {
+				p = seg.Points[2]
+			} else {
+				p = seg.Points[0]
+			}
+		}
+		path.Move(p)
+		for i := len(sub) - 1; i >= 0; i-- {
+			if seg := sub[i]; seg.Type == CurveSegment {
+				path.Curve(seg.Points[1], seg.Points[0], starts[i])
+			} else {
+				path.Line(starts[i])
+			}
+		}
+		if closed {
+			path.Close()
+		}
+		sub = sub[:0]
+	}
+	pendingMove := false
+	for _, seg := range segs {
+		switch seg.Type {
+		case MoveSegment:
+			if pendingMove {
+				path.Move(from)
+			}
+			flush(false)
+			from, pendingMove = seg.Points[0], true
+		case LineSegment, CurveSegment:
+			sub = append(sub, seg)
+			pendingMove = false
+		case RectangleSegment:
+			if pendingMove {
+				path.Move(from)
+			}
+			flush(false)
+			pendingMove = false
+			// A rectangle with a negative width runs in the opposite
+			// direction.
+			min, max := seg.Points[0], seg.Points[1]
+			path.Rectangle(Rectangle{Point{max.X, min.Y}, Point{min.X, max.Y}})
+			from = min
+		case CloseSegment:
+			if pendingMove {
+				path.Move(from)
+				pendingMove = false
+			}
+			if len(sub) > 0 {
+				flush(true)
+			} else {
+				// A closed subpath without segments, such as a dot,
+				// stays closed.
+				path.Close()
+			}
+		}
+	}
+	if pendingMove {
+		path.Move(from)
+	}
+	flush(false)
+}
+
+// writeTo writes the path construction operators for the path.
+func (path *Path) writeTo(w io.Writer) error {
+	for _, seg := range path.segments {
+		pts := seg.Points
+		var err error
+		switch seg.Type {
+		case MoveSegment:
+			err = writeCommand(w, "m", pts[0].X, pts[0].Y)
+		case LineSegment:
+			err = writeCommand(w, "l", pts[0].X, pts[0].Y)
+		case CurveSegment:
+			err = writeCommand(w, "c", pts[0].X, pts[0].Y, pts[1].X, pts[1].Y, pts[2].X, pts[2].Y)
+		case RectangleSegment:
+			r := Rectangle{pts[0], pts[1]}
+			err = writeCommand(w, "re", r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+		case CloseSegment:
+			err = writeCommand(w, "h")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+// pathString returns the path construction operators for a path.
+func pathString(path *Path) string {
+	var buf bytes.Buffer
+	path.writeTo(&buf)
+	return buf.String()
+}
+
+func TestPathBounds(t *testing.T) {
+	tests := []struct {
+		Build    func(*Path)
+		Expected Rectangle
+	}{
+		{func(p *Path) {}, Rectangle{}},
+		{
+			func(p *Path) {
+				p.Move(Point{10, 20})
+				p.Line(Point{-5, 40})
+				p.Close()
+			},
+			Rectangle{Point{-5, 20}, Point{10, 40}},
+		},
+		{
+			func(p *Path) {
+				p.Rectangle(Rectangle{Point{30, 40}, Point{0, 10}})
+				p.Move(Point{5, 5})
+			},
+			Rectangle{Point{0, 5}, Point{30, 40}},
+		},
+		{
+			// The control points lie far above the curve's peak at y = 75.
+			func(p *Path) {
+				p.Move(Point{0, 0})
+				p.Curve(Point{0, 100}, Point{100, 100}, Point{100, 0})
+			},
+			Rectangle{Point{0, 0}, Point{100, 75}},
+		},
+		{
+			// After closing, the curve starts at the subpath's start.
+			func(p *Path) {
+				p.Move(Point{0, 0})
+				p.Line(Point{0, 10})
+				p.Close()
+				p.Curve(Point{100, 100}, Point{100, 100}, Point{0, 0})
+			},
+			Rectangle{Point{0, 0}, Point{75, 75}},
+		},
+		{
+			func(p *Path) { p.Circle(Point{50, 50}, 10) },
+			Rectangle{Point{40, 40}, Point{60, 60}},
+		},
+	}
+	for i, tt := range tests {
+		path := new(Path)
+		tt.Build(path)
+		if b := path.Bounds(); !rectanglesClose(b, tt.Expected) {
+			t.Errorf("%d. Bounds() = %v, expected %v", i, b, tt.Expected)
+		}
+	}
+}
+
+func rectanglesClose(a, b Rectangle) bool {
+	const epsilon = 1e-3
+	for _, d := range []Unit{a.Min.X - b.Min.X, a.Min.Y - b.Min.Y, a.Max.X - b.Max.X, a.Max.Y - b.Max.Y} {
+		if d < -epsilon || d > epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+const pathTransformExpectedOutput = `-2.00000 -10.00000 m
+-12.00000 -50.00000 l
+h
+10.00000 20.00000 m
+10.00000 40.00000 l
+-20.00000 40.00000 l
+-20.00000 20.00000 l
+h
+`
+
+func TestPathTransform(t *testing.T) {
+	path := new(Path)
+	path.Move(Point{-20, 12})
+	path.Line(Point{-60, 22})
+	path.Close()
+	path.Rectangle(Rectangle{Point{10, 0}, Point{30, 30}})
+	// Rotate by 90 degrees counter-clockwise and translate by (10, 10).
+	path.Transform(Matrix{0, 1, -1, 0, 10, 10})
+
+	if pathString(path) != pathTransformExpectedOutput {
+		t.Errorf("Output was %q, expected %q", pathString(path), pathTransformExpectedOutput)
+	}
+}
+
+func TestPathTransformScaleKeepsRectangles(t *testing.T) {
+	path := new(Path)
+	path.Rectangle(Rectangle{Point{1, 2}, Point{3, 4}})
+	path.Transform(Matrix{2, 0, 0, 3, 1, 1})
+
+	const expected = "3.00000 7.00000 4.00000 6.00000 re\n"
+	if pathString(path) != expected {
+		t.Errorf("Output was %q, expected %q", pathString(path), expected)
+	}
+}
+
+const pathAppendExpectedOutput = `0.00000 0.00000 m
+10.00000 0.00000 l
+5.00000 5.00000 m
+5.00000 15.00000 l
+`
+
+func TestPathAppend(t *testing.T) {
+	path := new(Path)
+	path.Move(Point{0, 0})
+	path.Line(Point{10, 0})
+	other := new(Path)
+	other.Move(Point{5, 5})
+	other.Line(Point{5, 15})
+	path.Append(other)
+
+	if pathString(path) != pathAppendExpectedOutput {
+		t.Errorf("Output was %q, expected %q", pathString(path), pathAppendExpectedOutput)
+	}
+	if other.Len() != 2 {
+		t.Errorf("Append modified its argument: %d segments", other.Len())
+	}
+	path.QuadCurve(Point{20, 15}, Point{20, 5})
+	if segs := path.Segments(); segs[len(segs)-1].Points[2] != (Point{20, 5}) {
+		t.Errorf("current point after Append is wrong: %v", segs[len(segs)-1])
+	}
+}
+
+const pathReverseExpectedOutput = `30.00000 30.00000 m
+20.00000 20.00000 10.00000 10.00000 0.00000 0.00000 c
+h
+5.00000 0.00000 m
+5.00000 5.00000 l
+5.00000 0.00000 -5.00000 5.00000 re
+`
+
+func TestPathReverse(t *testing.T) {
+	path := new(Path)
+	path.Move(Point{0, 0})
+	path.Curve(Point{10, 10}, Point{20, 20}, Point{30, 30})
+	path.Close()
+	path.Move(Point{5, 5})
+	path.Line(Point{5, 0})
+	path.Rectangle(Rectangle{Point{0, 0}, Point{5, 5}})
+	path.Reverse()
+
+	if pathString(path) != pathReverseExpectedOutput {
+		t.Errorf("Output was %q, expected %q", pathString(path), pathReverseExpectedOutput)
+	}
+}
+
+func TestPathReverseDot(t *testing.T) {
+	path := new(Path)
+	path.Move(Point{1, 1})
+	path.Close()
+	path.Reverse()
+
+	const expected = "1.00000 1.00000 m\nh\n"
+	if pathString(path) != expected {
+		t.Errorf("Output was %q, expected %q", pathString(path), expected)
+	}
+}
+
+func TestPathReuse(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	path := new(Path)
+	path.Rectangle(Rectangle{Point{1, 2}, Point{3, 4}})
+	canvas.Fill(path)
+	canvas.Stroke(path)
+	canvas.Close()
+
+	const expected = "1.00000 2.00000 2.00000 2.00000 re\nf\n1.00000 2.00000 2.00000 2.00000 re\nS\n"
+	if output := canvasOutput(t, canvas); output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+// Tiling pattern paint types
+const (
+	coloredPaintType   = 1
+	uncoloredPaintType = 2
+)
+
+const (
+	tilingPatternType     = 1
+	constantSpacingTiling = 1
+)
+
+// tilingPattern is a pattern that replicates a cell drawn with a content
+// stream.  See Section 8.7.3 of ISO 32000-1.
+type tilingPattern struct {
+	*stream
+	PaintType int
+	BBox      Rectangle
+	XStep     Unit
+	YStep     Unit
+	Resources resources
+	Matrix    Matrix
+}
+
+type tilingPatternInfo struct {
+	Type        name
+	PatternType int
+	PaintType   int
+	TilingType  int
+	BBox        Rectangle
+	XStep       Unit
+	YStep       Unit
+	Resources   resources
+	Matrix      Matrix
+	Length      int
+	Filter      name `pdf:",omitempty"`
+}
+
+func (pat *tilingPattern) marshalPDF(dst []byte) ([]byte, error) {
+	return marshalStream(dst, tilingPatternInfo{
+		Type:        patternType,
+		PatternType: tilingPatternType,
+		PaintType:   pat.PaintType,
+		TilingType:  constantSpacingTiling,
+		BBox:        pat.BBox,
+		XStep:       pat.XStep,
+		YStep:       pat.YStep,
+		Resources:   pat.Resources,
+		Matrix:      pat.Matrix,
+		Length:      pat.Len(),
+		Filter:      pat.filter,
+	}, pat.Bytes())
+}
+
+// NewTilingPattern creates a tiling pattern and returns a canvas for drawing
+// its pattern cell.  The cell is clipped to the cell rectangle and repeated
+// every xstep units horizontally and ystep units vertically.  Like shading
+// patterns, the pattern's coordinates are transformed by m into the default
+// coordinate system of the page.
+//
+// A colored pattern paints the cell with the colors used on its canvas and is
+// selected with Canvas.SetFillPattern or Canvas.SetStrokePattern.  An
+// uncolored pattern is a stencil: the cell must not set any colors, and the
+// color is chosen when the pattern is selected with
+// Canvas.SetUncoloredFillPattern or Canvas.SetUncoloredStrokePattern.
+//
+// Close must be called on the returned canvas once drawing has completed.
+// The pattern is identified by the canvas's Reference.
+func (doc *Document) NewTilingPattern(cell Rectangle, xstep, ystep Unit, m Matrix, colored bool) *Canvas {
+	pat := &tilingPattern{
+		stream:    newStream(streamFlateDecode),
+		PaintType: uncoloredPaintType,
+		BBox:      cell,
+		XStep:     xstep,
+		YStep:     ystep,
+		Resources: newResources(),
+		Matrix:    m,
+	}
+	if colored {
+		pat.PaintType = coloredPaintType
+	}
+	return doc.addCanvas(&Canvas{
+		doc:       doc,
+		ref:       doc.add(pat),
+		contents:  pat.stream,
+		resources: &pat.Resources,
+		mediaBox:  &pat.BBox,
+		cropBox:   &pat.BBox,
+		state:     newGraphicsState(0),
+	})
+}
+
+const anonymousColorSpaceFormat = "__cs%d__"
+
+// uncoloredPatternColorSpace is a pattern color space whose underlying color
+// space supplies the color of uncolored patterns.
+var uncoloredPatternColorSpace = [2]name{patternColorSpace, deviceRGBColorSpace}
+
+// SetUncoloredFillPattern changes the current fill color to an uncolored
+// tiling pattern painted in the given RGB triple (in device RGB space).
+func (canvas *Canvas) SetUncoloredFillPattern(pattern Reference, r, g, b float32) {
+	cs := resourceName(&canvas.resources.ColorSpace, anonymousColorSpaceFormat, uncoloredPatternColorSpace)
+	n := resourceName(&canvas.resources.Pattern, anonymousPatternFormat, pattern)
+	canvas.command("cs", cs)
+	canvas.command("scn", r, g, b, n)
+	canvas.state.known &^= knownFillColor
+}
+
+// SetUncoloredStrokePattern changes the current stroke color to an uncolored
+// tiling pattern painted in the given RGB triple (in device RGB space).
+func (canvas *Canvas) SetUncoloredStrokePattern(pattern Reference, r, g, b float32) {
+	cs := resourceName(&canvas.resources.ColorSpace, anonymousColorSpaceFormat, uncoloredPatternColorSpace)
+	n := resourceName(&canvas.resources.Pattern, anonymousPatternFormat, pattern)
+	canvas.command("CS", cs)
+	canvas.command("SCN", r, g, b, n)
+	canvas.state.known &^= knownStrokeColor
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+const tilingPatternExpectedOutput = `/__cs0__ cs
+1.00000 0.00000 0.00000 /__pattern0__ scn
+/__cs0__ CS
+0.00000 0.00000 1.00000 /__pattern0__ SCN
+`
+
+func TestTilingPattern(t *testing.T) {
+	doc := New()
+	cell := doc.NewTilingPattern(Rectangle{Point{0, 0}, Point{10, 10}}, 10, 10, IdentityMatrix, false)
+	cell.DrawLine(Point{0, 0}, Point{10, 10})
+	cell.Close()
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetUncoloredFillPattern(cell.Reference(), 1, 0, 0)
+	canvas.SetUncoloredStrokePattern(cell.Reference(), 0, 0, 1)
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != tilingPatternExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, tilingPatternExpectedOutput)
+	}
+	if len(canvas.resources.ColorSpace) != 1 {
+		t.Errorf("Got %d color spaces, expected 1", len(canvas.resources.ColorSpace))
+	}
+	b, _ := marshal(nil, canvas.resources.ColorSpace["__cs0__"])
+	if string(b) != "[ /Pattern /DeviceRGB ]" {
+		t.Errorf("Pattern color space is %q", b)
+	}
+
+	pat := doc.objects[cell.Reference().Number-1].(*tilingPattern)
+	if pat.PaintType != uncoloredPaintType {
+		t.Errorf("PaintType = %d, expected %d", pat.PaintType, uncoloredPaintType)
+	}
+}
+
+const coloredTilingPatternExpectedOutput = `/Pattern cs
+/__pattern0__ scn
+/Pattern CS
+/__pattern0__ SCN
+`
+
+func TestColoredTilingPattern(t *testing.T) {
+	doc := New()
+	cell := doc.NewTilingPattern(Rectangle{Point{0, 0}, Point{10, 10}}, 20, 20, IdentityMatrix, true)
+	cell.SetColor(1, 0, 0)
+	cell.DrawLine(Point{0, 0}, Point{10, 10})
+	cell.Close()
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetFillPattern(cell.Reference())
+	canvas.SetStrokePattern(cell.Reference())
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != coloredTilingPatternExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, coloredTilingPatternExpectedOutput)
+	}
+	if len(canvas.resources.ColorSpace) != 0 {
+		t.Errorf("Got %d color spaces, expected none", len(canvas.resources.ColorSpace))
+	}
+
+	pat := doc.objects[cell.Reference().Number-1].(*tilingPattern)
+	b, err := pat.marshalPDF(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dict := string(b[:strings.Index(string(b), streamBegin)])
+	for _, entry := range []string{
+		"/Type /Pattern",
+		"/PatternType 1",
+		"/PaintType 1",
+		"/TilingType 1",
+		"/XStep 20",
+		"/YStep 20",
+	} {
+		if !strings.Contains(dict, entry) {
+			t.Errorf("Pattern dictionary %q does not contain %q", dict, entry)
+		}
+	}
+}
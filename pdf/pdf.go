@@ -3,6 +3,7 @@
 package pdf
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"image"
 	"io"
@@ -39,6 +40,17 @@ type Document struct {
 	catalog *catalog
 	pages   []indirectObject
 	fonts   map[name]*Font
+
+	// canvases lists every canvas created for the document, so that Encode
+	// can report their errors.
+	canvases []*Canvas
+	// err is the first error encountered while adding objects that are
+	// not drawn on a canvas.
+	err error
+
+	extGStates  map[string]Reference
+	images      map[[sha256.Size]byte]Reference
+	iccProfiles map[[sha256.Size]byte]Reference
 }
 
 // New creates a new document with no pages.
@@ -49,20 +61,19 @@ func New() *Document {
 	}
 	doc.root = doc.add(doc.catalog)
 	doc.fonts = make(map[name]*Font)
+	doc.extGStates = make(map[string]Reference)
+	doc.images = make(map[[sha256.Size]byte]Reference)
+	doc.iccProfiles = make(map[[sha256.Size]byte]Reference)
 	return doc
 }
 
 // NewPage creates a new canvas with the given dimensions.
 func (doc *Document) NewPage(width, height Unit) *Canvas {
 	page := &pageDict{
-		Type:     pageType,
-		MediaBox: Rectangle{Point{0, 0}, Point{width, height}},
-		CropBox:  Rectangle{Point{0, 0}, Point{width, height}},
-		Resources: resources{
-			ProcSet: []name{pdfProcSet, textProcSet, imageCProcSet},
-			Font:    make(map[name]interface{}),
-			XObject: make(map[name]interface{}),
-		},
+		Type:      pageType,
+		MediaBox:  Rectangle{Point{0, 0}, Point{width, height}},
+		CropBox:   Rectangle{Point{0, 0}, Point{width, height}},
+		Resources: newResources(),
 	}
 	pageRef := doc.add(page)
 	doc.pages = append(doc.pages, indirectObject{pageRef, page})
@@ -70,12 +81,22 @@ func (doc *Document) NewPage(width, height Unit) *Canvas {
 	stream := newStream(streamFlateDecode)
 	page.Contents = doc.add(stream)
 
-	return &Canvas{
-		doc:      doc,
-		page:     page,
-		ref:      pageRef,
-		contents: stream,
-	}
+	return doc.addCanvas(&Canvas{
+		doc:       doc,
+		page:      page,
+		ref:       pageRef,
+		contents:  stream,
+		resources: &page.Resources,
+		mediaBox:  &page.MediaBox,
+		cropBox:   &page.CropBox,
+		state:     newGraphicsState(knownAll),
+	})
+}
+
+// addCanvas records a new canvas in the document.
+func (doc *Document) addCanvas(canvas *Canvas) *Canvas {
+	doc.canvases = append(doc.canvases, canvas)
+	return canvas
 }
 
 func (doc *Document) AddFont(font, encoding name) (*Font, error) {
@@ -124,27 +145,59 @@ func (doc *Document) AddFont(font, encoding name) (*Font, error) {
 
 // AddImage encodes an image into the document's stream and returns its PDF
 // file reference.  This reference can be used to draw the image multiple times
-// without storing the image multiple times.
+// without storing the image multiple times.  If the image is not opaque, its
+// alpha channel is stored as a soft mask, except for paletted images with a
+// single transparent color, which is masked out by its palette index.
+//
+// Images are stored only once even if they are added several times: adding an
+// image that is identical to one added before returns the existing reference.
 func (doc *Document) AddImage(img image.Image) Reference {
-	bd := img.Bounds()
-	st := newImageStream(streamFlateDecode, bd.Dx(), bd.Dy())
-	defer st.Close()
-
-	switch i := img.(type) {
-	case *image.RGBA:
-		encodeRGBAStream(st, i)
-	case *image.NRGBA:
-		encodeNRGBAStream(st, i)
-	case *image.YCbCr:
-		encodeYCbCrStream(st, i)
-	default:
-		encodeImageStream(st, i)
+	return doc.AddImageWithOptions(img, nil)
+}
+
+// addImageStream adds a closed image stream to the document, unless an
+// identical image has already been added.  It returns the reference of the
+// image in the document.
+func (doc *Document) addImageStream(st *imageStream) Reference {
+	dict, err := marshal(nil, st.info())
+	if err != nil {
+		doc.setErr(err)
+		return doc.add(st)
+	}
+	h := sha256.New()
+	h.Write(dict)
+	h.Write(st.Bytes())
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	if ref, ok := doc.images[sum]; ok {
+		return ref
+	}
+	ref := doc.add(st)
+	doc.images[sum] = ref
+	return ref
+}
+
+// setErr records err unless an earlier error has already been recorded.
+func (doc *Document) setErr(err error) {
+	if doc.err == nil {
+		doc.err = err
 	}
-	return doc.add(st)
 }
 
-// Encode writes the document to a writer in the PDF format.
+// Encode writes the document to a writer in the PDF format.  If adding
+// objects to the document or drawing on any of its canvases failed, or a
+// canvas has an unmatched Push or marked content sequence, Encode returns the
+// first such error without writing anything.
 func (doc *Document) Encode(w io.Writer) error {
+	if doc.err != nil {
+		return doc.err
+	}
+	for _, canvas := range doc.canvases {
+		canvas.checkBalance()
+		if canvas.err != nil {
+			return canvas.err
+		}
+	}
 	pageRoot := &pageRootNode{
 		Type:  pageNodeType,
 		Count: len(doc.pages),
@@ -161,23 +214,30 @@ func (doc *Document) Encode(w io.Writer) error {
 
 // PDF object types
 const (
-	catalogType  name = "Catalog"
-	pageNodeType name = "Pages"
-	pageType     name = "Page"
-	fontType     name = "Font"
-	xobjectType  name = "XObject"
+	catalogType   name = "Catalog"
+	pageNodeType  name = "Pages"
+	pageType      name = "Page"
+	fontType      name = "Font"
+	xobjectType   name = "XObject"
+	extGStateType name = "ExtGState"
+	groupType     name = "Group"
+	maskType      name = "Mask"
+	patternType   name = "Pattern"
+	ocgType       name = "OCG"
 )
 
 // PDF object subtypes
 const (
 	imageSubtype name = "Image"
+	formSubtype  name = "Form"
 
 	fontType1Subtype name = "Type1"
 )
 
 type catalog struct {
-	Type  name
-	Pages Reference
+	Type         name
+	Pages        Reference
+	OCProperties *ocProperties `pdf:",omitempty"`
 }
 
 type pageRootNode struct {
@@ -222,6 +282,58 @@ func (r Rectangle) Dy() Unit {
 	return r.Max.Y - r.Min.Y
 }
 
+// Matrix is an affine transformation matrix.  The elements map to values in
+// the 3x3 matrix as shown below:
+//
+//	/ m[0] m[1] 0 \
+//	| m[2] m[3] 0 |
+//	\ m[4] m[5] 1 /
+//
+// For more information, see Section 8.3.4 of ISO 32000-1.
+type Matrix [6]float32
+
+// IdentityMatrix is the matrix that leaves all coordinates unchanged.
+var IdentityMatrix = Matrix{1, 0, 0, 1, 0, 0}
+
+// Multiply returns the matrix product m × n, which is the transformation that
+// applies m, then n.
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// Apply returns the point pt transformed by m.
+func (m Matrix) Apply(pt Point) Point {
+	x, y := float32(pt.X), float32(pt.Y)
+	return Point{
+		Unit(m[0]*x + m[2]*y + m[4]),
+		Unit(m[1]*x + m[3]*y + m[5]),
+	}
+}
+
+// Invert returns the inverse of m.  If m is singular, and therefore has no
+// inverse, Invert returns m unchanged and false.
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return m, false
+	}
+	return Matrix{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
 func (r Rectangle) marshalPDF(dst []byte) ([]byte, error) {
 	dst = append(dst, '[', ' ')
 	dst, _ = marshal(dst, r.Min.X)
@@ -236,9 +348,22 @@ func (r Rectangle) marshalPDF(dst []byte) ([]byte, error) {
 }
 
 type resources struct {
-	ProcSet []name
-	Font    map[name]interface{}
-	XObject map[name]interface{}
+	ProcSet    []name
+	Font       map[name]interface{}
+	XObject    map[name]interface{}
+	ExtGState  map[name]interface{} `pdf:",omitempty"`
+	Shading    map[name]interface{} `pdf:",omitempty"`
+	Pattern    map[name]interface{} `pdf:",omitempty"`
+	ColorSpace map[name]interface{} `pdf:",omitempty"`
+	Properties map[name]interface{} `pdf:",omitempty"`
+}
+
+func newResources() resources {
+	return resources{
+		ProcSet: []name{pdfProcSet, textProcSet, imageCProcSet},
+		Font:    make(map[name]interface{}),
+		XObject: make(map[name]interface{}),
+	}
 }
 
 // Predefined procedure sets
@@ -0,0 +1,267 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"io/ioutil"
+)
+
+// pngPredictor is the Predictor value for PNG predictors that may differ
+// from row to row.  See Table 8 of ISO 32000-1.
+const pngPredictor = 15
+
+// decodeParms holds the parameters of a Flate-encoded stream that uses a
+// predictor.
+type decodeParms struct {
+	Predictor        int
+	Colors           int
+	BitsPerComponent int
+	Columns          int
+}
+
+// PNG filter types
+const (
+	pngFilterNone = iota
+	pngFilterSub
+	pngFilterUp
+	pngFilterAverage
+	pngFilterPaeth
+	pngFilterCount
+)
+
+// predictorWriter applies PNG filters to rows of image data before writing
+// them.  For each row it uses the filter that is most likely to compress
+// well, like most PNG encoders do.
+type predictorWriter struct {
+	w   io.Writer
+	bpp int // bytes per complete pixel, at least 1
+
+	prev, cur []byte
+	n         int // number of bytes in cur
+	filtered  [pngFilterCount][]byte
+}
+
+func newPredictorWriter(w io.Writer, format imageFormat, width int) *predictorWriter {
+	rowBytes := format.dataSize(width, 1)
+	pw := &predictorWriter{
+		w:    w,
+		bpp:  (format.components*format.bitsPerComponent + 7) / 8,
+		prev: make([]byte, rowBytes),
+		cur:  make([]byte, rowBytes),
+	}
+	for i := range pw.filtered {
+		pw.filtered[i] = make([]byte, 1+rowBytes)
+		pw.filtered[i][0] = byte(i)
+	}
+	return pw
+}
+
+// predictorParms returns the decode parameters that reverse the PNG
+// predictors applied to an image of the given format and width.
+func predictorParms(format imageFormat, width int) *decodeParms {
+	return &decodeParms{
+		Predictor:        pngPredictor,
+		Colors:           format.components,
+		BitsPerComponent: format.bitsPerComponent,
+		Columns:          width,
+	}
+}
+
+func (pw *predictorWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		k := copy(pw.cur[pw.n:], p)
+		pw.n += k
+		p = p[k:]
+		written += k
+		if pw.n == len(pw.cur) {
+			if err := pw.writeRow(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func absByte(b byte) int {
+	if b < 128 {
+		return int(b)
+	}
+	return 256 - int(b)
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := p-int(a), p-int(b), p-int(c)
+	if pa < 0 {
+		pa = -pa
+	}
+	if pb < 0 {
+		pb = -pb
+	}
+	if pc < 0 {
+		pc = -pc
+	}
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// writeRow filters and writes the current row.
+func (pw *predictorWriter) writeRow() error {
+	cur, prev, bpp := pw.cur, pw.prev, pw.bpp
+	best, bestSum := 0, -1
+	for f := range pw.filtered {
+		out := pw.filtered[f][1:]
+		sum := 0
+		for i := range cur {
+			var a, c byte
+			if i >= bpp {
+				a, c = cur[i-bpp], prev[i-bpp]
+			}
+			b := prev[i]
+			switch f {
+			case pngFilterNone:
+				out[i] = cur[i]
+			case pngFilterSub:
+				out[i] = cur[i] - a
+			case pngFilterUp:
+				out[i] = cur[i] - b
+			case pngFilterAverage:
+				out[i] = cur[i] - byte((int(a)+int(b))/2)
+			case pngFilterPaeth:
+				out[i] = cur[i] - paeth(a, b, c)
+			}
+			sum += absByte(out[i])
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = f, sum
+		}
+	}
+	pw.prev, pw.cur = cur, prev
+	pw.n = 0
+	_, err := pw.w.Write(pw.filtered[best])
+	return err
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// PNG color types
+const (
+	pngGray      = 0
+	pngRGB       = 2
+	pngPaletted  = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+// AddPNG adds a PNG image to the document and returns its PDF file
+// reference.  The compressed image data of opaque, non-interlaced images is
+// copied into the document without being decoded; other images are decoded
+// and added as if by AddImage.
+func (doc *Document) AddPNG(r io.Reader) (Reference, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Reference{}, err
+	}
+	st, err := parsePNG(data)
+	if err == errPNGNeedsDecoding {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Reference{}, err
+		}
+		return doc.AddImage(img), nil
+	} else if err != nil {
+		return Reference{}, err
+	}
+	return doc.addImageStream(st), nil
+}
+
+var (
+	errNotPNG           = errors.New("pdf: not a PNG image")
+	errPNGNeedsDecoding = errors.New("pdf: PNG image cannot be embedded directly")
+)
+
+// parsePNG creates an image stream from the chunks of a PNG image.
+func parsePNG(data []byte) (*imageStream, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errNotPNG
+	}
+	data = data[len(pngSignature):]
+
+	var (
+		st      *imageStream
+		format  imageFormat
+		palette []byte
+		idat    bytes.Buffer
+	)
+	for {
+		if len(data) < 12 {
+			return nil, errNotPNG
+		}
+		n := binary.BigEndian.Uint32(data)
+		if uint64(n)+12 > uint64(len(data)) {
+			return nil, errNotPNG
+		}
+		typ, chunk := string(data[4:8]), data[8:8+n]
+		data = data[12+n:]
+
+		switch typ {
+		case "IHDR":
+			if len(chunk) != 13 {
+				return nil, errNotPNG
+			}
+			width := int(binary.BigEndian.Uint32(chunk[0:]))
+			height := int(binary.BigEndian.Uint32(chunk[4:]))
+			depth, colorType, interlace := int(chunk[8]), chunk[9], chunk[12]
+			if interlace != 0 {
+				return nil, errPNGNeedsDecoding
+			}
+			switch colorType {
+			case pngGray:
+				format = imageFormat{deviceGrayColorSpace, 1, depth}
+			case pngRGB:
+				format = imageFormat{deviceRGBColorSpace, 3, depth}
+			case pngPaletted:
+				format = imageFormat{indexedColorSpace, 1, depth}
+			case pngGrayAlpha, pngRGBA:
+				return nil, errPNGNeedsDecoding
+			default:
+				return nil, fmt.Errorf("pdf: unsupported PNG color type %d", colorType)
+			}
+			// The IDAT data is already Flate compressed and is copied as is.
+			st = newEncodedImageStream(streamFlateDecode, width, height)
+			st.BitsPerComponent = depth
+		case "PLTE":
+			palette = chunk
+		case "tRNS":
+			return nil, errPNGNeedsDecoding
+		case "IDAT":
+			idat.Write(chunk)
+		case "IEND":
+			if st == nil || idat.Len() == 0 {
+				return nil, errNotPNG
+			}
+			st.ColorSpace = format.colorSpace
+			if format.colorSpace == indexedColorSpace {
+				if len(palette) == 0 || len(palette)%3 != 0 {
+					return nil, errors.New("pdf: PNG image has no valid palette")
+				}
+				st.ColorSpace = []interface{}{indexedColorSpace, deviceRGBColorSpace, len(palette)/3 - 1, string(palette)}
+			}
+			st.DecodeParms = predictorParms(format, st.Width)
+			st.Write(idat.Bytes())
+			st.Close()
+			return st, nil
+		}
+	}
+}
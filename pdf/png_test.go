@@ -0,0 +1,163 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// unpredict reverses the PNG predictors applied to rows of rowBytes bytes.
+func unpredict(t *testing.T, data []byte, rowBytes, bpp int) []byte {
+	if len(data)%(rowBytes+1) != 0 {
+		t.Fatalf("predicted data has %d bytes, not a multiple of %d", len(data), rowBytes+1)
+	}
+	var out []byte
+	prev := make([]byte, rowBytes)
+	for len(data) > 0 {
+		f, row := data[0], data[1:rowBytes+1]
+		data = data[rowBytes+1:]
+		cur := make([]byte, rowBytes)
+		for i := range row {
+			var a, c byte
+			if i >= bpp {
+				a, c = cur[i-bpp], prev[i-bpp]
+			}
+			b := prev[i]
+			switch f {
+			case pngFilterNone:
+				cur[i] = row[i]
+			case pngFilterSub:
+				cur[i] = row[i] + a
+			case pngFilterUp:
+				cur[i] = row[i] + b
+			case pngFilterAverage:
+				cur[i] = row[i] + byte((int(a)+int(b))/2)
+			case pngFilterPaeth:
+				cur[i] = row[i] + paeth(a, b, c)
+			default:
+				t.Fatalf("unknown filter type %d", f)
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out
+}
+
+func TestPredictorWriter(t *testing.T) {
+	// A gradient is predicted well by every filter but None.
+	const w, h = 16, 4
+	raw := make([]byte, 3*w*h)
+	for i := range raw {
+		raw[i] = byte(i * 7 / 3)
+	}
+	var buf bytes.Buffer
+	pw := newPredictorWriter(&buf, imageFormat{deviceRGBColorSpace, 3, 8}, w)
+	// Write in pieces that do not line up with rows.
+	for p := raw; len(p) > 0; {
+		n := 5
+		if n > len(p) {
+			n = len(p)
+		}
+		pw.Write(p[:n])
+		p = p[n:]
+	}
+
+	data := buf.Bytes()
+	if len(data) != h*(3*w+1) {
+		t.Fatalf("len(data) = %d, expected %d", len(data), h*(3*w+1))
+	}
+	for y := 0; y < h; y++ {
+		if f := data[y*(3*w+1)]; f == pngFilterNone {
+			t.Errorf("row %d uses no filter", y)
+		}
+	}
+	if out := unpredict(t, data, 3*w, 3); !bytes.Equal(out, raw) {
+		t.Errorf("unpredicted data = %v, expected %v", out, raw)
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAddPNG(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 5, 3))
+	rgb := image.NewRGBA(image.Rect(0, 0, 5, 3))
+	for i := range rgb.Pix {
+		rgb.Pix[i] = 0xff
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, 5, 3), color.Palette{color.Black, color.White})
+
+	tests := []struct {
+		Image      image.Image
+		ColorSpace string
+		Colors     int
+	}{
+		{gray, "/DeviceGray", 1},
+		{rgb, "/DeviceRGB", 3},
+		{paletted, "[ /Indexed /DeviceRGB 1 <000000FFFFFF> ]", 1},
+	}
+	for i, tt := range tests {
+		doc := New()
+		ref, err := doc.AddPNG(bytes.NewReader(encodePNG(t, tt.Image)))
+		if err != nil {
+			t.Errorf("%d. AddPNG: %v", i, err)
+			continue
+		}
+		st := doc.objects[ref.Number-1].(*imageStream)
+		if cs, _ := marshal(nil, st.ColorSpace); string(cs) != tt.ColorSpace {
+			t.Errorf("%d. ColorSpace = %s, expected %s", i, cs, tt.ColorSpace)
+		}
+		expected := fmt.Sprintf("<< /Predictor 15 /Colors %d /BitsPerComponent %d /Columns 5 >>", tt.Colors, st.BitsPerComponent)
+		if parms, _ := marshal(nil, st.DecodeParms); string(parms) != expected {
+			t.Errorf("%d. DecodeParms = %s, expected %s", i, parms, expected)
+		}
+		if st.Width != 5 || st.Height != 3 {
+			t.Errorf("%d. size = %dx%d, expected 5x3", i, st.Width, st.Height)
+		}
+		if st.filter != streamFlateDecode {
+			t.Errorf("%d. filter = %v, expected %v", i, st.filter, streamFlateDecode)
+		}
+		// The IDAT data is a complete zlib stream of filtered rows.
+		format := formatOf(tt.Image)
+		if tt.Image == paletted {
+			format = imageFormat{deviceGrayColorSpace, 1, st.BitsPerComponent}
+		}
+		rowBytes := format.dataSize(5, 1)
+		data := unpredict(t, streamData(t, st.stream), rowBytes, 1)
+		if len(data) != 3*rowBytes {
+			t.Errorf("%d. decoded %d bytes, expected %d", i, len(data), 3*rowBytes)
+		}
+	}
+}
+
+func TestAddPNGWithAlpha(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 128})
+
+	doc := New()
+	ref, err := doc.AddPNG(bytes.NewReader(encodePNG(t, img)))
+	if err != nil {
+		t.Fatalf("AddPNG: %v", err)
+	}
+	if st := doc.objects[ref.Number-1].(*imageStream); st.SMask == nil {
+		t.Error("PNG with alpha has no soft mask")
+	}
+}
+
+func TestAddPNGInvalid(t *testing.T) {
+	doc := New()
+	if _, err := doc.AddPNG(bytes.NewReader(adobeCMYKHeader)); err == nil {
+		t.Error("AddPNG accepted a JPEG")
+	}
+}
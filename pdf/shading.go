@@ -0,0 +1,230 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"errors"
+	"sort"
+)
+
+// RGB is a color in the device RGB color space.  Each component ranges from 0
+// to 1.
+type RGB struct {
+	R, G, B float32
+}
+
+func (c RGB) components() []float32 {
+	return []float32{c.R, c.G, c.B}
+}
+
+// A ColorStop is a color at a given position along a gradient.  Offset ranges
+// from 0 (the start of the gradient) to 1 (the end of the gradient).
+type ColorStop struct {
+	Offset float32
+	Color  RGB
+}
+
+// Shading types
+const (
+	axialShadingType  = 2
+	radialShadingType = 3
+)
+
+// Function types
+const (
+	exponentialFunctionType = 2
+	stitchingFunctionType   = 3
+)
+
+// Pattern types
+const (
+	shadingPatternType = 2
+)
+
+// exponentialFunction is a type 2 function that interpolates between C0 and
+// C1.  See Section 7.10.3 of ISO 32000-1.
+type exponentialFunction struct {
+	FunctionType int
+	Domain       []float32
+	C0           []float32
+	C1           []float32
+	N            float32
+}
+
+// stitchingFunction is a type 3 function that combines several functions
+// into one.  See Section 7.10.4 of ISO 32000-1.
+type stitchingFunction struct {
+	FunctionType int
+	Domain       []float32
+	Functions    []exponentialFunction
+	Bounds       []float32
+	Encode       []float32
+}
+
+// shadingDict is a type 2 (axial) or type 3 (radial) shading dictionary.
+type shadingDict struct {
+	ShadingType int
+	ColorSpace  name
+	Coords      []Unit
+	Function    interface{}
+	Extend      []bool
+}
+
+type shadingPattern struct {
+	Type        name
+	PatternType int
+	Shading     Reference
+	Matrix      Matrix
+}
+
+var errNoColorStops = errors.New("pdf: gradient has no color stops")
+
+// gradientFunction returns a function that maps [0, 1] to the colors given by
+// stops.  Stops are sorted by offset; the first and last colors extend to the
+// ends of the domain.
+func gradientFunction(stops []ColorStop) (interface{}, error) {
+	if len(stops) == 0 {
+		return nil, errNoColorStops
+	}
+	s := make([]ColorStop, 0, len(stops)+2)
+	s = append(s, stops...)
+	for i := range s {
+		switch {
+		case s[i].Offset < 0:
+			s[i].Offset = 0
+		case s[i].Offset > 1:
+			s[i].Offset = 1
+		}
+	}
+	sort.SliceStable(s, func(i, j int) bool {
+		return s[i].Offset < s[j].Offset
+	})
+	if len(s) == 1 {
+		// A single stop is a constant color.
+		c := s[0].Color.components()
+		return exponentialFunction{
+			FunctionType: exponentialFunctionType,
+			Domain:       []float32{0, 1},
+			C0:           c,
+			C1:           c,
+			N:            1,
+		}, nil
+	}
+
+	if s[0].Offset > 0 {
+		s = append([]ColorStop{{0, s[0].Color}}, s...)
+	}
+	if s[len(s)-1].Offset < 1 {
+		s = append(s, ColorStop{1, s[len(s)-1].Color})
+	}
+
+	f := stitchingFunction{
+		FunctionType: stitchingFunctionType,
+		Domain:       []float32{0, 1},
+		Functions:    make([]exponentialFunction, 0, len(s)-1),
+		Bounds:       make([]float32, 0, len(s)-2),
+		Encode:       make([]float32, 0, 2*(len(s)-1)),
+	}
+	for i := 0; i+1 < len(s); i++ {
+		f.Functions = append(f.Functions, exponentialFunction{
+			FunctionType: exponentialFunctionType,
+			Domain:       []float32{0, 1},
+			C0:           s[i].Color.components(),
+			C1:           s[i+1].Color.components(),
+			N:            1,
+		})
+		if i > 0 {
+			f.Bounds = append(f.Bounds, s[i].Offset)
+		}
+		f.Encode = append(f.Encode, 0, 1)
+	}
+	if len(f.Functions) == 1 {
+		return f.Functions[0], nil
+	}
+	return f, nil
+}
+
+// AddLinearGradient adds an axial shading to the document that blends the
+// colors in stops along the line from start to end.  The colors are extended
+// beyond both ends of the line.  The returned reference can be painted with
+// Canvas.PaintShading or used as a fill with AddShadingPattern.
+func (doc *Document) AddLinearGradient(start, end Point, stops []ColorStop) (Reference, error) {
+	f, err := gradientFunction(stops)
+	if err != nil {
+		return Reference{}, err
+	}
+	return doc.add(shadingDict{
+		ShadingType: axialShadingType,
+		ColorSpace:  deviceRGBColorSpace,
+		Coords:      []Unit{start.X, start.Y, end.X, end.Y},
+		Function:    f,
+		Extend:      []bool{true, true},
+	}), nil
+}
+
+// AddRadialGradient adds a radial shading to the document that blends the
+// colors in stops between the circle centered at c0 with radius r0 (offset 0)
+// and the circle centered at c1 with radius r1 (offset 1).  The colors are
+// extended beyond both circles.  The returned reference can be painted with
+// Canvas.PaintShading or used as a fill with AddShadingPattern.
+func (doc *Document) AddRadialGradient(c0 Point, r0 Unit, c1 Point, r1 Unit, stops []ColorStop) (Reference, error) {
+	f, err := gradientFunction(stops)
+	if err != nil {
+		return Reference{}, err
+	}
+	return doc.add(shadingDict{
+		ShadingType: radialShadingType,
+		ColorSpace:  deviceRGBColorSpace,
+		Coords:      []Unit{c0.X, c0.Y, r0, c1.X, c1.Y, r1},
+		Function:    f,
+		Extend:      []bool{true, true},
+	}), nil
+}
+
+// AddShadingPattern adds a pattern that paints a shading to the document.
+// The pattern can be used as a color with Canvas.SetFillPattern or
+// Canvas.SetStrokePattern.
+//
+// Unlike PaintShading, a pattern does not use the canvas's current
+// transformation: the shading's coordinates are transformed by m into the
+// default coordinate system of the page (or of the XObject the pattern is
+// used in).
+func (doc *Document) AddShadingPattern(shading Reference, m Matrix) Reference {
+	return doc.add(shadingPattern{
+		Type:        patternType,
+		PatternType: shadingPatternType,
+		Shading:     shading,
+		Matrix:      m,
+	})
+}
+
+const (
+	anonymousShadingFormat = "__shading%d__"
+	anonymousPatternFormat = "__pattern%d__"
+)
+
+const patternColorSpace name = "Pattern"
+
+// PaintShading fills the current clipping region with a shading added by
+// AddLinearGradient or AddRadialGradient.  The shading's coordinates are
+// interpreted in the canvas's current coordinate system.
+func (canvas *Canvas) PaintShading(shading Reference) {
+	n := resourceName(&canvas.resources.Shading, anonymousShadingFormat, shading)
+	canvas.command("sh", n)
+}
+
+// SetFillPattern changes the current fill color to a pattern.
+func (canvas *Canvas) SetFillPattern(pattern Reference) {
+	n := resourceName(&canvas.resources.Pattern, anonymousPatternFormat, pattern)
+	canvas.command("cs", patternColorSpace)
+	canvas.command("scn", n)
+	canvas.state.known &^= knownFillColor
+}
+
+// SetStrokePattern changes the current stroke color to a pattern.
+func (canvas *Canvas) SetStrokePattern(pattern Reference) {
+	n := resourceName(&canvas.resources.Pattern, anonymousPatternFormat, pattern)
+	canvas.command("CS", patternColorSpace)
+	canvas.command("SCN", n)
+	canvas.state.known &^= knownStrokeColor
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"testing"
+)
+
+type gradientFunctionTest struct {
+	Stops    []ColorStop
+	Expected string
+}
+
+var gradientFunctionTests = []gradientFunctionTest{
+	{
+		[]ColorStop{{0.5, RGB{1, 0, 0}}},
+		`<< /FunctionType 2 /Domain [ 0.00000 1.00000 ] /C0 [ 1.00000 0.00000 0.00000 ] /C1 [ 1.00000 0.00000 0.00000 ] /N 1.00000 >>`,
+	},
+	{
+		[]ColorStop{{1, RGB{0, 0, 1}}, {0, RGB{1, 0, 0}}},
+		`<< /FunctionType 2 /Domain [ 0.00000 1.00000 ] /C0 [ 1.00000 0.00000 0.00000 ] /C1 [ 0.00000 0.00000 1.00000 ] /N 1.00000 >>`,
+	},
+	{
+		[]ColorStop{{0, RGB{1, 0, 0}}, {0.25, RGB{0, 1, 0}}, {1, RGB{0, 0, 1}}},
+		`<< /FunctionType 3 /Domain [ 0.00000 1.00000 ] /Functions [ ` +
+			`<< /FunctionType 2 /Domain [ 0.00000 1.00000 ] /C0 [ 1.00000 0.00000 0.00000 ] /C1 [ 0.00000 1.00000 0.00000 ] /N 1.00000 >> ` +
+			`<< /FunctionType 2 /Domain [ 0.00000 1.00000 ] /C0 [ 0.00000 1.00000 0.00000 ] /C1 [ 0.00000 0.00000 1.00000 ] /N 1.00000 >> ` +
+			`] /Bounds [ 0.25000 ] /Encode [ 0.00000 1.00000 0.00000 1.00000 ] >>`,
+	},
+}
+
+func TestGradientFunction(t *testing.T) {
+	for i, tt := range gradientFunctionTests {
+		f, err := gradientFunction(tt.Stops)
+		if err != nil {
+			t.Errorf("%d. gradientFunction(%v) error: %v", i, tt.Stops, err)
+			continue
+		}
+		if b, _ := marshal(nil, f); string(b) != tt.Expected {
+			t.Errorf("%d. gradientFunction(%v) = %q, expected %q", i, tt.Stops, b, tt.Expected)
+		}
+	}
+	if _, err := gradientFunction(nil); err == nil {
+		t.Error("gradientFunction(nil) did not return an error")
+	}
+}
+
+const shadingExpectedOutput = `/__shading0__ sh
+/Pattern cs
+/__pattern0__ scn
+/Pattern CS
+/__pattern0__ SCN
+`
+
+func TestShading(t *testing.T) {
+	doc := New()
+	shading, err := doc.AddRadialGradient(Point{50, 50}, 0, Point{50, 50}, 50, []ColorStop{
+		{0, RGB{1, 1, 1}},
+		{1, RGB{0, 0, 0}},
+	})
+	if err != nil {
+		t.Fatalf("AddRadialGradient error: %v", err)
+	}
+	pattern := doc.AddShadingPattern(shading, IdentityMatrix)
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.PaintShading(shading)
+	canvas.SetFillPattern(pattern)
+	canvas.SetStrokePattern(pattern)
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != shadingExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, shadingExpectedOutput)
+	}
+	if canvas.resources.Shading["__shading0__"] != shading {
+		t.Errorf("Shading resources are %v", canvas.resources.Shading)
+	}
+	if canvas.resources.Pattern["__pattern0__"] != pattern {
+		t.Errorf("Pattern resources are %v", canvas.resources.Pattern)
+	}
+}
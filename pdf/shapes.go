@@ -0,0 +1,151 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"math"
+)
+
+// maxArcSegment is the largest angle approximated by a single Bézier curve.
+// For a quarter circle, the approximation deviates from the true circle by
+// less than 0.03% of the radius.
+const maxArcSegment = math.Pi / 2
+
+// ellipticalArc appends Bézier curves approximating an elliptical arc to the
+// path.  The ellipse is centered at c with radii rx and ry, and its x axis is
+// rotated by phi.  The arc starts at the parametric angle theta and sweeps by
+// dtheta (both in radians); the current point must already be at the start of
+// the arc.
+func (path *Path) ellipticalArc(c Point, rx, ry Unit, phi, theta, dtheta float64) {
+	n := int(math.Ceil(math.Abs(dtheta)/maxArcSegment - 1e-6))
+	if n < 1 {
+		n = 1
+	}
+	d := dtheta / float64(n)
+	k := 4.0 / 3.0 * math.Tan(d/4)
+	sinPhi, cosPhi := math.Sincos(phi)
+	pt := func(x, y float64) Point {
+		x, y = x*float64(rx), y*float64(ry)
+		return Point{
+			c.X + Unit(x*cosPhi-y*sinPhi),
+			c.Y + Unit(x*sinPhi+y*cosPhi),
+		}
+	}
+	for i := 0; i < n; i++ {
+		a1, a2 := theta+float64(i)*d, theta+float64(i+1)*d
+		s1, c1 := math.Sincos(a1)
+		s2, c2 := math.Sincos(a2)
+		path.Curve(
+			pt(c1-k*s1, s1+k*c1),
+			pt(c2+k*s2, s2-k*c2),
+			pt(c2, s2),
+		)
+	}
+}
+
+// QuadCurve appends a quadratic Bezier curve with the control point ctrl to
+// the path.  The curve is converted exactly into a cubic Bezier curve.
+func (path *Path) QuadCurve(ctrl, end Point) {
+	cur := path.current
+	path.Curve(
+		Point{cur.X + (ctrl.X-cur.X)*2/3, cur.Y + (ctrl.Y-cur.Y)*2/3},
+		Point{end.X + (ctrl.X-end.X)*2/3, end.Y + (ctrl.Y-end.Y)*2/3},
+		end,
+	)
+}
+
+// Arc appends a circular arc to the path.  The arc starts at the angle start
+// and ends at the angle end (both in radians, counter-clockwise from the
+// positive x axis); if end is less than start, the arc is drawn clockwise.  If
+// the path has a current point, a line segment is added from it to the start
+// of the arc; otherwise a new subpath begins at the start of the arc.
+func (path *Path) Arc(center Point, radius Unit, start, end float32) {
+	s, c := math.Sincos(float64(start))
+	pt := Point{center.X + radius*Unit(c), center.Y + radius*Unit(s)}
+	if path.hasCurrent {
+		path.Line(pt)
+	} else {
+		path.Move(pt)
+	}
+	path.ellipticalArc(center, radius, radius, 0, float64(start), float64(end-start))
+}
+
+// Circle appends a complete circle to the path as a new subpath.
+func (path *Path) Circle(center Point, radius Unit) {
+	path.Ellipse(center, radius, radius)
+}
+
+// Ellipse appends a complete axis-aligned ellipse to the path as a new
+// subpath.
+func (path *Path) Ellipse(center Point, rx, ry Unit) {
+	path.Move(Point{center.X + rx, center.Y})
+	path.ellipticalArc(center, rx, ry, 0, 0, 2*math.Pi)
+	path.Close()
+}
+
+// RoundedRectangle appends a rectangle with corners rounded to the given
+// radius to the path as a new subpath.  The radius is limited to half of the
+// rectangle's width and height.
+func (path *Path) RoundedRectangle(rect Rectangle, radius Unit) {
+	if rect.Min.X > rect.Max.X {
+		rect.Min.X, rect.Max.X = rect.Max.X, rect.Min.X
+	}
+	if rect.Min.Y > rect.Max.Y {
+		rect.Min.Y, rect.Max.Y = rect.Max.Y, rect.Min.Y
+	}
+	if r := rect.Dx() / 2; radius > r {
+		radius = r
+	}
+	if r := rect.Dy() / 2; radius > r {
+		radius = r
+	}
+	if radius <= 0 {
+		path.Rectangle(rect)
+		return
+	}
+
+	min, max := rect.Min, rect.Max
+	path.Move(Point{min.X + radius, min.Y})
+	path.Line(Point{max.X - radius, min.Y})
+	path.ellipticalArc(Point{max.X - radius, min.Y + radius}, radius, radius, 0, -math.Pi/2, math.Pi/2)
+	path.Line(Point{max.X, max.Y - radius})
+	path.ellipticalArc(Point{max.X - radius, max.Y - radius}, radius, radius, 0, 0, math.Pi/2)
+	path.Line(Point{min.X + radius, max.Y})
+	path.ellipticalArc(Point{min.X + radius, max.Y - radius}, radius, radius, 0, math.Pi/2, math.Pi/2)
+	path.Line(Point{min.X, min.Y + radius})
+	path.ellipticalArc(Point{min.X + radius, min.Y + radius}, radius, radius, 0, math.Pi, math.Pi/2)
+	path.Close()
+}
+
+// Polygon appends a closed polygon through the given points to the path as a
+// new subpath.
+func (path *Path) Polygon(pts []Point) {
+	if len(pts) == 0 {
+		return
+	}
+	path.Move(pts[0])
+	for _, pt := range pts[1:] {
+		path.Line(pt)
+	}
+	path.Close()
+}
+
+// Star appends a star with n points to the path as a new subpath.  The
+// points lie on a circle with the outer radius and the inner corners on a
+// circle with the inner radius, both centered at center.  The first point
+// points straight up.
+func (path *Path) Star(center Point, outer, inner Unit, n int) {
+	if n < 2 {
+		return
+	}
+	pts := make([]Point, 2*n)
+	for i := range pts {
+		r := outer
+		if i%2 == 1 {
+			r = inner
+		}
+		s, c := math.Sincos(math.Pi/2 + float64(i)*math.Pi/float64(n))
+		pts[i] = Point{center.X + r*Unit(c), center.Y + r*Unit(s)}
+	}
+	path.Polygon(pts)
+}
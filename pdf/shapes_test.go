@@ -0,0 +1,121 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pathCurves parses the cubic Bezier curves from a path's operators.  Each
+// curve is returned with its starting point.
+func pathCurves(t *testing.T, path *Path) [][4]Point {
+	var curves [][4]Point
+	var cur Point
+	for _, line := range strings.Split(strings.TrimSpace(pathString(path)), "\n") {
+		fields := strings.Fields(line)
+		args := make([]Unit, len(fields)-1)
+		for i := range args {
+			f, err := strconv.ParseFloat(fields[i], 32)
+			if err != nil {
+				t.Fatalf("bad path operator %q: %v", line, err)
+			}
+			args[i] = Unit(f)
+		}
+		switch fields[len(fields)-1] {
+		case "m", "l":
+			cur = Point{args[0], args[1]}
+		case "c":
+			c := [4]Point{cur, {args[0], args[1]}, {args[2], args[3]}, {args[4], args[5]}}
+			curves = append(curves, c)
+			cur = c[3]
+		}
+	}
+	return curves
+}
+
+func bezierPoint(c [4]Point, t float64) (x, y float64) {
+	mt := 1 - t
+	a, b, cc, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+	x = a*float64(c[0].X) + b*float64(c[1].X) + cc*float64(c[2].X) + d*float64(c[3].X)
+	y = a*float64(c[0].Y) + b*float64(c[1].Y) + cc*float64(c[2].Y) + d*float64(c[3].Y)
+	return
+}
+
+func TestCircleError(t *testing.T) {
+	const r = 100
+	path := new(Path)
+	path.Circle(Point{50, -20}, r)
+	curves := pathCurves(t, path)
+	if len(curves) != 4 {
+		t.Fatalf("circle has %d curves, expected 4", len(curves))
+	}
+	for i, c := range curves {
+		for j := 0; j <= 16; j++ {
+			x, y := bezierPoint(c, float64(j)/16)
+			if d := math.Hypot(x-50, y+20) - r; math.Abs(d) > 0.03 {
+				t.Errorf("curve %d at t=%d/16 deviates from circle by %g", i, j, d)
+			}
+		}
+	}
+}
+
+func TestArc(t *testing.T) {
+	path := new(Path)
+	path.Move(Point{0, 0})
+	path.Arc(Point{0, 0}, 10, 0, math.Pi)
+	path.Close()
+	curves := pathCurves(t, path)
+	if len(curves) != 2 {
+		t.Fatalf("half circle has %d curves, expected 2", len(curves))
+	}
+	if end := curves[1][3]; math.Abs(float64(end.X+10)) > 1e-4 || math.Abs(float64(end.Y)) > 1e-4 {
+		t.Errorf("arc ends at %v, expected {-10 0}", end)
+	}
+	if !strings.HasPrefix(pathString(path), "0.00000 0.00000 m\n10.00000 0.00000 l\n") {
+		t.Errorf("arc does not begin with a line to its start: %q", pathString(path))
+	}
+}
+
+const quadCurveExpectedOutput = `0.00000 0.00000 m
+20.00000 40.00000 50.00000 50.00000 90.00000 30.00000 c
+`
+
+func TestQuadCurve(t *testing.T) {
+	path := new(Path)
+	path.Move(Point{0, 0})
+	path.QuadCurve(Point{30, 60}, Point{90, 30})
+	if pathString(path) != quadCurveExpectedOutput {
+		t.Errorf("Output was %q, expected %q", pathString(path), quadCurveExpectedOutput)
+	}
+}
+
+const starExpectedOutput = `0.00000 10.00000 m
+-5.00000 0.00000 l
+-0.00000 -10.00000 l
+5.00000 -0.00000 l
+h
+`
+
+func TestStar(t *testing.T) {
+	path := new(Path)
+	path.Star(Point{0, 0}, 10, 5, 2)
+	if pathString(path) != starExpectedOutput {
+		t.Errorf("Output was %q, expected %q", pathString(path), starExpectedOutput)
+	}
+}
+
+func TestRoundedRectangle(t *testing.T) {
+	path := new(Path)
+	path.RoundedRectangle(Rectangle{Point{0, 0}, Point{100, 20}}, 50)
+	curves := pathCurves(t, path)
+	if len(curves) != 4 {
+		t.Fatalf("rounded rectangle has %d curves, expected 4", len(curves))
+	}
+	// The radius is limited to half the height, so the corners meet.
+	if start := curves[1][0]; start != (Point{100, 10}) {
+		t.Errorf("second corner starts at %v, expected {100 10}", start)
+	}
+}
@@ -11,6 +11,8 @@ const (
 	streamNoFilter    name = ""
 	streamLZWDecode   name = "LZWDecode"
 	streamFlateDecode name = "FlateDecode"
+	streamDCTDecode   name = "DCTDecode"
+	streamCCITTFax    name = "CCITTFaxDecode"
 )
 
 // stream is a blob of data stored in a PDF file.
@@ -33,6 +35,9 @@ func newStream(filter name) *stream {
 		st.writer = lzw.NewWriter(&st.Buffer, lzw.MSB, 8)
 	case streamFlateDecode:
 		st.writer = zlib.NewWriter(&st.Buffer)
+	case streamDCTDecode, streamCCITTFax:
+		// The data must already be encoded.
+		st.writer = &st.Buffer
 	default:
 		// TODO: warn about bad filter names?
 		st.writer = &st.Buffer
@@ -40,6 +45,14 @@ func newStream(filter name) *stream {
 	return st
 }
 
+// newEncodedStream returns a stream for data that is already encoded with
+// filter, such as the Flate data of a PNG file.  Writes are stored as is.
+func newEncodedStream(filter name) *stream {
+	st := &stream{filter: filter}
+	st.writer = &st.Buffer
+	return st
+}
+
 func (st *stream) ReadFrom(r io.Reader) (n int64, err error) {
 	return io.Copy(st.writer, r)
 }
@@ -42,6 +42,19 @@ func TestFlateStream(t *testing.T) {
 	}
 }
 
+func TestEncodedStream(t *testing.T) {
+	st := newEncodedStream(streamFlateDecode)
+	st.WriteString(streamTestString)
+	st.Close()
+
+	if st.String() != streamTestString {
+		t.Errorf("Stream is %q, wanted %q", st.String(), streamTestString)
+	}
+	if st.filter != streamFlateDecode {
+		t.Errorf("Stream filter is %q, wanted %q", st.filter, streamFlateDecode)
+	}
+}
+
 const expectedMarshalStreamOutput = "<< /Length 15 >> stream\r\n" + streamTestString + "\r\nendstream"
 
 func TestMarshalStream(t *testing.T) {
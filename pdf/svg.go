@@ -0,0 +1,990 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVG is a parsed Scalable Vector Graphics document that can be drawn onto a
+// canvas as vector graphics.
+//
+// The supported subset of SVG covers the basic shapes (rect, circle,
+// ellipse, line, polyline, polygon and path), groups, use elements,
+// transforms, fill and stroke properties including opacity, linear and
+// radial gradients, clip paths and basic text drawn with the standard 14
+// fonts.  Presentation attributes may be given as attributes or in style
+// attributes; style sheets, filters, markers, masks and stop opacity are
+// ignored.  Transforms on the children of clip paths are not supported.
+type SVG struct {
+	root *svgNode
+	ids  map[string]*svgNode
+
+	width, height Unit
+	viewBox       [4]float64
+}
+
+// svgNode is an element of an SVG document.
+type svgNode struct {
+	name     string
+	attrs    map[string]string
+	children []*svgNode
+	text     string
+}
+
+// ParseSVG reads an SVG document.
+func ParseSVG(r io.Reader) (*SVG, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	svg := &SVG{ids: make(map[string]*svgNode)}
+	var stack []*svgNode
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &svgNode{name: tok.Name.Local, attrs: make(map[string]string)}
+			for _, a := range tok.Attr {
+				if a.Name.Local == "href" {
+					// Both href and xlink:href.
+					n.attrs["href"] = a.Value
+				} else if a.Name.Space == "" || a.Name.Space == "http://www.w3.org/2000/svg" {
+					n.attrs[a.Name.Local] = a.Value
+				}
+			}
+			// Style declarations take precedence over attributes.
+			for _, decl := range strings.Split(n.attrs["style"], ";") {
+				if i := strings.Index(decl, ":"); i >= 0 {
+					k := strings.TrimSpace(decl[:i])
+					n.attrs[k] = strings.TrimSpace(decl[i+1:])
+				}
+			}
+			if id := n.attrs["id"]; id != "" {
+				svg.ids[id] = n
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if svg.root == nil {
+				svg.root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				n := stack[len(stack)-1]
+				n.text += string(tok)
+			}
+		}
+	}
+	if svg.root == nil || svg.root.name != "svg" {
+		return nil, errors.New("pdf: not an SVG document")
+	}
+
+	// Determine the document size.  SVG user units are CSS pixels, which
+	// are 3/4 of a point.
+	const pxPerPt = 96.0 / 72.0
+	attrs := svg.root.attrs
+	vb := svgNumbers(attrs["viewBox"])
+	w, h := svgLength(attrs["width"], 0, 0), svgLength(attrs["height"], 0, 0)
+	if len(vb) == 4 && vb[2] > 0 && vb[3] > 0 {
+		copy(svg.viewBox[:], vb)
+		if w <= 0 {
+			w = vb[2]
+		}
+		if h <= 0 {
+			h = vb[3]
+		}
+	} else {
+		if w <= 0 {
+			w = 300
+		}
+		if h <= 0 {
+			h = 150
+		}
+		svg.viewBox = [4]float64{0, 0, w, h}
+	}
+	svg.width, svg.height = Unit(w/pxPerPt), Unit(h/pxPerPt)
+	return svg, nil
+}
+
+// Size returns the size of the SVG document.
+func (svg *SVG) Size() (width, height Unit) {
+	return svg.width, svg.height
+}
+
+// Draw paints the SVG document onto a canvas.  The document occupies the
+// rectangle from the origin to the point given by its size.
+func (svg *SVG) Draw(canvas *Canvas) {
+	vb := svg.viewBox
+	sx, sy := float64(svg.width)/vb[2], float64(svg.height)/vb[3]
+	tx, ty := 0.0, 0.0
+	if svg.root.attrs["preserveAspectRatio"] != "none" {
+		// Scale uniformly and center the view box.
+		s := math.Min(sx, sy)
+		tx = (float64(svg.width) - vb[2]*s) / 2
+		ty = (float64(svg.height) - vb[3]*s) / 2
+		sx, sy = s, s
+	}
+
+	r := &svgRenderer{
+		svg:       svg,
+		canvas:    canvas,
+		gradients: make(map[*svgNode]Reference),
+		using:     make(map[*svgNode]bool),
+	}
+	canvas.Push()
+	// Set SVG's initial line style once, so that elements using it do not
+	// repeat it.
+	canvas.SetLineCap(ButtCap)
+	canvas.SetLineJoin(MiterJoin)
+	canvas.SetMiterLimit(4)
+	// Flip the y axis, since SVG's y axis points down.
+	canvas.Transform(1, 0, 0, -1, float32(tx), float32(svg.height)-float32(ty))
+	canvas.Transform(float32(sx), 0, 0, float32(sy), float32(0-vb[0]*sx), float32(0-vb[1]*sy))
+	r.drawChildren(svg.root, defaultSVGStyle().inherit(r, svg.root.attrs))
+	canvas.Pop()
+}
+
+// AddSVG stores an SVG document in the document as a template.  The returned
+// reference can be painted with Canvas.DrawTemplate.
+func (doc *Document) AddSVG(svg *SVG) Reference {
+	template := doc.NewTemplate(Rectangle{Point{0, 0}, Point{svg.width, svg.height}})
+	svg.Draw(template)
+	template.Close()
+	return template.Reference()
+}
+
+// svgPaint is a fill or stroke paint.
+type svgPaint struct {
+	none     bool
+	color    RGB
+	gradient *svgNode
+}
+
+// svgStyle holds the inherited presentation properties.
+type svgStyle struct {
+	fill, stroke      svgPaint
+	color             RGB
+	fillOpacity       float64
+	strokeOpacity     float64
+	opacity           float64
+	fillRule          string
+	strokeWidth       float64
+	lineCap, lineJoin string
+	miterLimit        float64
+	dashArray         []Unit
+	dashOffset        float64
+	fontFamily        string
+	fontSize          float64
+	fontWeight        int
+	fontStyle         string
+	textAnchor        string
+}
+
+func defaultSVGStyle() *svgStyle {
+	return &svgStyle{
+		fill:          svgPaint{},
+		stroke:        svgPaint{none: true},
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		opacity:       1,
+		strokeWidth:   1,
+		miterLimit:    4,
+		fontFamily:    "sans-serif",
+		fontSize:      16,
+		fontWeight:    400,
+	}
+}
+
+// inherit returns the style of an element with the given attributes whose
+// parent has style st.
+func (st *svgStyle) inherit(r *svgRenderer, attrs map[string]string) *svgStyle {
+	s := *st
+	if v, ok := attrs["color"]; ok {
+		if c, ok := parseSVGColor(v, s.color); ok {
+			s.color = c
+		}
+	}
+	if v, ok := attrs["fill"]; ok {
+		s.fill = r.parsePaint(v, s.fill, s.color)
+	}
+	if v, ok := attrs["stroke"]; ok {
+		s.stroke = r.parsePaint(v, s.stroke, s.color)
+	}
+	if v, ok := attrs["fill-opacity"]; ok {
+		s.fillOpacity = svgOpacity(v)
+	}
+	if v, ok := attrs["stroke-opacity"]; ok {
+		s.strokeOpacity = svgOpacity(v)
+	}
+	if v, ok := attrs["opacity"]; ok {
+		// Group opacity is approximated by applying it to each object.
+		s.opacity *= svgOpacity(v)
+	}
+	if v, ok := attrs["fill-rule"]; ok {
+		s.fillRule = v
+	}
+	if v, ok := attrs["stroke-width"]; ok {
+		s.strokeWidth = svgLength(v, 0, s.fontSize)
+	}
+	if v, ok := attrs["stroke-linecap"]; ok {
+		s.lineCap = v
+	}
+	if v, ok := attrs["stroke-linejoin"]; ok {
+		s.lineJoin = v
+	}
+	if v, ok := attrs["stroke-miterlimit"]; ok {
+		s.miterLimit = svgLength(v, 0, s.fontSize)
+	}
+	if v, ok := attrs["stroke-dasharray"]; ok {
+		s.dashArray = nil
+		if v != "none" {
+			for _, f := range svgNumbers(v) {
+				s.dashArray = append(s.dashArray, Unit(f))
+			}
+			if len(s.dashArray)%2 == 1 {
+				s.dashArray = append(s.dashArray, s.dashArray...)
+			}
+		}
+	}
+	if v, ok := attrs["stroke-dashoffset"]; ok {
+		s.dashOffset = svgLength(v, 0, s.fontSize)
+	}
+	if v, ok := attrs["font-size"]; ok {
+		s.fontSize = svgLength(v, st.fontSize, st.fontSize)
+	}
+	if v, ok := attrs["font-family"]; ok {
+		s.fontFamily = v
+	}
+	if v, ok := attrs["font-weight"]; ok {
+		s.fontWeight = svgFontWeight(v, st.fontWeight)
+	}
+	if v, ok := attrs["font-style"]; ok {
+		s.fontStyle = v
+	}
+	if v, ok := attrs["text-anchor"]; ok {
+		s.textAnchor = v
+	}
+	return &s
+}
+
+type svgRenderer struct {
+	svg       *SVG
+	canvas    *Canvas
+	gradients map[*svgNode]Reference
+	// using holds the targets of the use elements being drawn, so that
+	// cyclic references are not followed.
+	using map[*svgNode]bool
+}
+
+func (r *svgRenderer) drawChildren(n *svgNode, st *svgStyle) {
+	for _, child := range n.children {
+		r.draw(child, st)
+	}
+}
+
+// draw paints an element and its children.
+func (r *svgRenderer) draw(n *svgNode, parent *svgStyle) {
+	if n.attrs["display"] == "none" {
+		return
+	}
+	switch n.name {
+	case "g", "a", "svg", "use", "path", "rect", "circle", "ellipse", "line", "polyline", "polygon", "text":
+	default:
+		// Definitions and unsupported elements are not painted.
+		return
+	}
+	st := parent.inherit(r, n.attrs)
+
+	r.canvas.Push()
+	defer r.canvas.Pop()
+	if m, ok := parseSVGTransform(n.attrs["transform"]); ok {
+		r.canvas.Transform(m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+	if clip := r.svg.ref(n.attrs["clip-path"]); clip != nil && clip.name == "clipPath" {
+		r.clip(clip, st)
+	}
+
+	switch n.name {
+	case "g", "a":
+		r.drawChildren(n, st)
+	case "svg":
+		// Nested documents are positioned, but their view box is ignored.
+		x, y := svgLength(n.attrs["x"], 0, st.fontSize), svgLength(n.attrs["y"], 0, st.fontSize)
+		r.canvas.Translate(Unit(x), Unit(y))
+		r.drawChildren(n, st)
+	case "use":
+		if target := r.svg.ref(n.attrs["href"]); target != nil && !r.using[target] {
+			r.using[target] = true
+			defer delete(r.using, target)
+			x, y := svgLength(n.attrs["x"], 0, st.fontSize), svgLength(n.attrs["y"], 0, st.fontSize)
+			r.canvas.Translate(Unit(x), Unit(y))
+			if target.name == "symbol" {
+				r.drawChildren(target, st.inherit(r, target.attrs))
+			} else {
+				r.draw(target, st)
+			}
+		}
+	case "text":
+		r.drawText(n, st)
+	default:
+		r.drawShape(n, st)
+	}
+}
+
+// ref returns the element referenced by a "#id" or "url(#id)" value.
+func (svg *SVG) ref(v string) *svgNode {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "url(") {
+		v = strings.TrimSuffix(strings.TrimPrefix(v, "url("), ")")
+		v = strings.Trim(strings.TrimSpace(v), `'"`)
+	}
+	if !strings.HasPrefix(v, "#") {
+		return nil
+	}
+	return svg.ids[v[1:]]
+}
+
+// buildShape appends the geometry of a shape element to a path.  It returns
+// false if the element has no geometry.
+func (r *svgRenderer) buildShape(path *Path, n *svgNode, st *svgStyle) bool {
+	vb := r.svg.viewBox
+	length := func(attr string, ref float64) float64 {
+		return svgLength(n.attrs[attr], ref, st.fontSize)
+	}
+	switch n.name {
+	case "path":
+		// Like SVG renderers, draw the path up to the first error.
+		path.appendSVG(n.attrs["d"])
+	case "rect":
+		x, y := length("x", vb[2]), length("y", vb[3])
+		w, h := length("width", vb[2]), length("height", vb[3])
+		if w <= 0 || h <= 0 {
+			return false
+		}
+		rx, hasRX := n.attrs["rx"]
+		ry, hasRY := n.attrs["ry"]
+		if !hasRX {
+			rx = ry
+		}
+		if !hasRY {
+			ry = rx
+		}
+		rect := Rectangle{Point{Unit(x), Unit(y)}, Point{Unit(x + w), Unit(y + h)}}
+		radius := math.Min(svgLength(rx, vb[2], st.fontSize), svgLength(ry, vb[3], st.fontSize))
+		path.RoundedRectangle(rect, Unit(radius))
+	case "circle":
+		rad := length("r", math.Hypot(vb[2], vb[3])/math.Sqrt2)
+		if rad <= 0 {
+			return false
+		}
+		path.Circle(Point{Unit(length("cx", vb[2])), Unit(length("cy", vb[3]))}, Unit(rad))
+	case "ellipse":
+		rx, ry := length("rx", vb[2]), length("ry", vb[3])
+		if rx <= 0 || ry <= 0 {
+			return false
+		}
+		path.Ellipse(Point{Unit(length("cx", vb[2])), Unit(length("cy", vb[3]))}, Unit(rx), Unit(ry))
+	case "line":
+		path.Move(Point{Unit(length("x1", vb[2])), Unit(length("y1", vb[3]))})
+		path.Line(Point{Unit(length("x2", vb[2])), Unit(length("y2", vb[3]))})
+	case "polyline", "polygon":
+		f := svgNumbers(n.attrs["points"])
+		if len(f) < 4 {
+			return false
+		}
+		path.Move(Point{Unit(f[0]), Unit(f[1])})
+		for i := 2; i+1 < len(f); i += 2 {
+			path.Line(Point{Unit(f[i]), Unit(f[i+1])})
+		}
+		if n.name == "polygon" {
+			path.Close()
+		}
+	default:
+		return false
+	}
+	return path.hasCurrent
+}
+
+// drawShape fills and strokes a shape element.
+func (r *svgRenderer) drawShape(n *svgNode, st *svgStyle) {
+	var path Path
+	if !r.buildShape(&path, n, st) {
+		return
+	}
+	fill, stroke := st.fill, st.stroke
+	if n.name == "line" {
+		// Lines have no interior.
+		fill.none = true
+	}
+	if st.strokeWidth <= 0 {
+		stroke.none = true
+	}
+	var strokePattern Reference
+	if !stroke.none && stroke.gradient != nil {
+		if ref, ok := r.gradientPattern(stroke.gradient, path.Bounds()); ok {
+			strokePattern = ref
+		} else {
+			stroke.none = true
+		}
+	}
+	if fill.none && stroke.none {
+		return
+	}
+	r.setAlpha(st, fill, stroke)
+
+	evenOdd := st.fillRule == "evenodd"
+	if !fill.none && fill.gradient != nil {
+		r.canvas.Push()
+		r.clipPath(&path, evenOdd)
+		r.paintGradient(fill.gradient, path.Bounds())
+		r.canvas.Pop()
+		if stroke.none {
+			return
+		}
+		fill.none = true
+	}
+
+	if !fill.none {
+		c := fill.color
+		r.canvas.SetColor(c.R, c.G, c.B)
+	}
+	if !stroke.none {
+		r.setStroke(st, stroke, strokePattern)
+	}
+	switch {
+	case !fill.none && !stroke.none && evenOdd:
+		r.canvas.paintPath(&path, "B*")
+	case !fill.none && !stroke.none:
+		r.canvas.FillStroke(&path)
+	case !fill.none && evenOdd:
+		r.canvas.paintPath(&path, "f*")
+	case !fill.none:
+		r.canvas.Fill(&path)
+	default:
+		r.canvas.Stroke(&path)
+	}
+}
+
+// setAlpha applies the opacity properties if they are not opaque.
+func (r *svgRenderer) setAlpha(st *svgStyle, fill, stroke svgPaint) {
+	fa, sa := st.fillOpacity*st.opacity, st.strokeOpacity*st.opacity
+	if fill.none {
+		fa = 1
+	}
+	if stroke.none {
+		sa = 1
+	}
+	if fa < 1 || sa < 1 {
+		r.canvas.SetAlpha(float32(fa), float32(sa))
+	}
+}
+
+// setStroke applies the stroke paint and line style.  A gradient stroke is
+// painted with pattern, which gradientPattern returned for the gradient.
+func (r *svgRenderer) setStroke(st *svgStyle, stroke svgPaint, pattern Reference) {
+	if stroke.gradient != nil {
+		r.canvas.SetStrokePattern(pattern)
+	} else {
+		c := stroke.color
+		r.canvas.SetStrokeColor(c.R, c.G, c.B)
+	}
+	r.canvas.SetLineWidth(Unit(st.strokeWidth))
+	switch st.lineCap {
+	case "round":
+		r.canvas.SetLineCap(RoundCap)
+	case "square":
+		r.canvas.SetLineCap(SquareCap)
+	default:
+		r.canvas.SetLineCap(ButtCap)
+	}
+	switch st.lineJoin {
+	case "round":
+		r.canvas.SetLineJoin(RoundJoin)
+	case "bevel":
+		r.canvas.SetLineJoin(BevelJoin)
+	default:
+		r.canvas.SetLineJoin(MiterJoin)
+	}
+	if st.miterLimit >= 1 {
+		r.canvas.SetMiterLimit(float32(st.miterLimit))
+	}
+	if len(st.dashArray) > 0 {
+		r.canvas.SetLineDash(Unit(st.dashOffset), st.dashArray)
+	}
+}
+
+func (r *svgRenderer) clipPath(path *Path, evenOdd bool) {
+	if evenOdd {
+		r.canvas.paintPath(path, "W* n")
+	} else {
+		r.canvas.Clip(path)
+	}
+}
+
+// clip intersects the clipping path with the shapes in a clipPath element.
+func (r *svgRenderer) clip(n *svgNode, st *svgStyle) {
+	st = st.inherit(r, n.attrs)
+	var path Path
+	for _, child := range n.children {
+		cst := st.inherit(r, child.attrs)
+		if v, ok := child.attrs["clip-rule"]; ok {
+			cst.fillRule = v
+		} else if v, ok := n.attrs["clip-rule"]; ok {
+			cst.fillRule = v
+		}
+		r.buildShape(&path, child, cst)
+	}
+	m, hasTransform := parseSVGTransform(n.attrs["transform"])
+	if hasTransform {
+		r.canvas.Transform(m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+	if path.hasCurrent {
+		r.clipPath(&path, n.attrs["clip-rule"] == "evenodd")
+	} else {
+		// An empty clip path hides the element completely.
+		path.Rectangle(Rectangle{})
+		r.canvas.Clip(&path)
+	}
+	if hasTransform {
+		m, _ = m.Invert()
+		r.canvas.Transform(m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+}
+
+// gradientStops returns the color stops of a gradient element, following
+// href links to other gradients.
+func (r *svgRenderer) gradientStops(n *svgNode) []ColorStop {
+	for depth := 0; n != nil && depth < 16; depth++ {
+		var stops []ColorStop
+		for _, child := range n.children {
+			if child.name != "stop" {
+				continue
+			}
+			offset := svgLength(child.attrs["offset"], 1, 0)
+			c, _ := parseSVGColor(child.attrs["stop-color"], RGB{})
+			stops = append(stops, ColorStop{float32(offset), c})
+		}
+		if len(stops) > 0 {
+			return stops
+		}
+		n = r.svg.ref(n.attrs["href"])
+	}
+	return []ColorStop{{0, RGB{}}}
+}
+
+// gradientAttr returns a gradient attribute, following href links to other
+// gradients.
+func (r *svgRenderer) gradientAttr(n *svgNode, attr string) (string, bool) {
+	for depth := 0; n != nil && depth < 16; depth++ {
+		if v, ok := n.attrs[attr]; ok {
+			return v, true
+		}
+		n = r.svg.ref(n.attrs["href"])
+	}
+	return "", false
+}
+
+// gradientShading returns the shading of a gradient element, adding it to
+// the document the first time it is used.
+func (r *svgRenderer) gradientShading(n *svgNode) Reference {
+	if ref, ok := r.gradients[n]; ok {
+		return ref
+	}
+	units, _ := r.gradientAttr(n, "gradientUnits")
+	// Percentages refer to the bounding box or to the view box.
+	refW, refH := 1.0, 1.0
+	if units == "userSpaceOnUse" {
+		refW, refH = r.svg.viewBox[2], r.svg.viewBox[3]
+	}
+	attr := func(name, def string, ref float64) float64 {
+		v, ok := r.gradientAttr(n, name)
+		if !ok {
+			v = def
+		}
+		return svgLength(v, ref, 0)
+	}
+
+	var ref Reference
+	stops := r.gradientStops(n)
+	if n.name == "radialGradient" {
+		cx, cy := attr("cx", "50%", refW), attr("cy", "50%", refH)
+		rad := attr("r", "50%", math.Hypot(refW, refH)/math.Sqrt2)
+		fx, fy := cx, cy
+		if _, ok := r.gradientAttr(n, "fx"); ok {
+			fx = attr("fx", "", refW)
+		}
+		if _, ok := r.gradientAttr(n, "fy"); ok {
+			fy = attr("fy", "", refH)
+		}
+		ref, _ = r.canvas.doc.AddRadialGradient(Point{Unit(fx), Unit(fy)}, 0, Point{Unit(cx), Unit(cy)}, Unit(rad), stops)
+	} else {
+		p0 := Point{Unit(attr("x1", "0%", refW)), Unit(attr("y1", "0%", refH))}
+		p1 := Point{Unit(attr("x2", "100%", refW)), Unit(attr("y2", "0%", refH))}
+		ref, _ = r.canvas.doc.AddLinearGradient(p0, p1, stops)
+	}
+	r.gradients[n] = ref
+	return ref
+}
+
+// gradientMatrix returns the transformation from the coordinate system of a
+// gradient element to the current user space.  bbox is the bounding box of
+// the element being painted.  It returns false if the gradient is relative
+// to an empty bounding box, in which case it is not painted.
+func (r *svgRenderer) gradientMatrix(n *svgNode, bbox Rectangle) (Matrix, bool) {
+	m := IdentityMatrix
+	if v, ok := r.gradientAttr(n, "gradientTransform"); ok {
+		if t, ok := parseSVGTransform(v); ok {
+			m = t
+		}
+	}
+	if units, _ := r.gradientAttr(n, "gradientUnits"); units != "userSpaceOnUse" {
+		w, h := bbox.Dx(), bbox.Dy()
+		if w == 0 || h == 0 {
+			return m, false
+		}
+		m = m.Multiply(Matrix{float32(w), 0, 0, float32(h), float32(bbox.Min.X), float32(bbox.Min.Y)})
+	}
+	return m, true
+}
+
+// paintGradient paints a gradient element into the current clipping path.
+// bbox is the bounding box of the element being filled.
+func (r *svgRenderer) paintGradient(n *svgNode, bbox Rectangle) {
+	m, ok := r.gradientMatrix(n, bbox)
+	if !ok {
+		return
+	}
+	ref := r.gradientShading(n)
+	r.canvas.Transform(m[0], m[1], m[2], m[3], m[4], m[5])
+	r.canvas.PaintShading(ref)
+}
+
+// gradientPattern returns a shading pattern that paints a gradient element
+// in the current user space, for stroking an element with bounding box bbox.
+// It returns false if the gradient is not painted.
+func (r *svgRenderer) gradientPattern(n *svgNode, bbox Rectangle) (Reference, bool) {
+	m, ok := r.gradientMatrix(n, bbox)
+	if !ok {
+		return Reference{}, false
+	}
+	// Patterns ignore the current transformation, so it becomes part of
+	// the pattern matrix.
+	return r.canvas.doc.AddShadingPattern(r.gradientShading(n), m.Multiply(r.canvas.Matrix())), true
+}
+
+// drawText paints a text element, including the text of its tspan children,
+// as a single line.
+func (r *svgRenderer) drawText(n *svgNode, st *svgStyle) {
+	var sb strings.Builder
+	var collect func(*svgNode)
+	collect = func(n *svgNode) {
+		sb.WriteString(n.text)
+		for _, child := range n.children {
+			if child.name == "tspan" {
+				collect(child)
+			}
+		}
+	}
+	collect(n)
+	s := strings.Join(strings.Fields(sb.String()), " ")
+	if s == "" || st.fill.none {
+		return
+	}
+
+	c := st.fill.color
+	if st.fill.gradient != nil {
+		c = r.gradientStops(st.fill.gradient)[0].Color
+	}
+	r.setAlpha(st, st.fill, svgPaint{none: true})
+	r.canvas.SetColor(c.R, c.G, c.B)
+
+	x := svgLength(firstSVGValue(n.attrs["x"]), r.svg.viewBox[2], st.fontSize)
+	y := svgLength(firstSVGValue(n.attrs["y"]), r.svg.viewBox[3], st.fontSize)
+	text := new(Text)
+	text.SetFont(svgFont(st), Unit(st.fontSize))
+	switch st.textAnchor {
+	case "middle":
+		x -= float64(text.currFont.Width(s, Unit(st.fontSize))) / 2
+	case "end":
+		x -= float64(text.currFont.Width(s, Unit(st.fontSize)))
+	}
+	text.Text(s)
+
+	// Flip the text back upright at its baseline.
+	r.canvas.Transform(1, 0, 0, -1, float32(x), float32(y))
+	r.canvas.DrawText(text)
+}
+
+// svgFont chooses the standard font closest to the font properties.
+func svgFont(st *svgStyle) string {
+	family := strings.ToLower(st.fontFamily)
+	bold := st.fontWeight >= 600
+	italic := st.fontStyle == "italic" || st.fontStyle == "oblique"
+	switch {
+	case strings.Contains(family, "mono") || strings.Contains(family, "courier"):
+		return [...]string{Courier, CourierOblique, CourierBold, CourierBoldOblique}[svgFontStyleIndex(bold, italic)]
+	case strings.Contains(family, "sans") || strings.Contains(family, "helvetica") || strings.Contains(family, "arial"):
+		return [...]string{Helvetica, HelveticaOblique, HelveticaBold, HelveticaBoldOblique}[svgFontStyleIndex(bold, italic)]
+	case strings.Contains(family, "serif") || strings.Contains(family, "times"):
+		return [...]string{Times, TimesItalic, TimesBold, TimesBoldItalic}[svgFontStyleIndex(bold, italic)]
+	}
+	return [...]string{Helvetica, HelveticaOblique, HelveticaBold, HelveticaBoldOblique}[svgFontStyleIndex(bold, italic)]
+}
+
+func svgFontStyleIndex(bold, italic bool) int {
+	i := 0
+	if italic {
+		i++
+	}
+	if bold {
+		i += 2
+	}
+	return i
+}
+
+// parsePaint parses a fill or stroke value.
+func (r *svgRenderer) parsePaint(v string, inherited svgPaint, current RGB) svgPaint {
+	v = strings.TrimSpace(v)
+	switch v {
+	case "none", "transparent":
+		return svgPaint{none: true}
+	case "inherit":
+		return inherited
+	case "currentColor":
+		return svgPaint{color: current}
+	}
+	if strings.HasPrefix(v, "url(") {
+		if n := r.svg.ref(v); n != nil && (n.name == "linearGradient" || n.name == "radialGradient") {
+			return svgPaint{gradient: n}
+		}
+		// Use the fallback color, if any.
+		if i := strings.Index(v, ")"); i >= 0 {
+			return r.parsePaint(v[i+1:], svgPaint{none: true}, current)
+		}
+		return svgPaint{none: true}
+	}
+	if c, ok := parseSVGColor(v, current); ok {
+		return svgPaint{color: c}
+	}
+	return inherited
+}
+
+var svgColorNames = map[string]RGB{
+	"black":   {0, 0, 0},
+	"silver":  {0xc0 / 255.0, 0xc0 / 255.0, 0xc0 / 255.0},
+	"gray":    {0x80 / 255.0, 0x80 / 255.0, 0x80 / 255.0},
+	"grey":    {0x80 / 255.0, 0x80 / 255.0, 0x80 / 255.0},
+	"white":   {1, 1, 1},
+	"maroon":  {0x80 / 255.0, 0, 0},
+	"red":     {1, 0, 0},
+	"purple":  {0x80 / 255.0, 0, 0x80 / 255.0},
+	"fuchsia": {1, 0, 1},
+	"magenta": {1, 0, 1},
+	"green":   {0, 0x80 / 255.0, 0},
+	"lime":    {0, 1, 0},
+	"olive":   {0x80 / 255.0, 0x80 / 255.0, 0},
+	"yellow":  {1, 1, 0},
+	"navy":    {0, 0, 0x80 / 255.0},
+	"blue":    {0, 0, 1},
+	"teal":    {0, 0x80 / 255.0, 0x80 / 255.0},
+	"aqua":    {0, 1, 1},
+	"cyan":    {0, 1, 1},
+	"orange":  {1, 0xa5 / 255.0, 0},
+	"brown":   {0xa5 / 255.0, 0x2a / 255.0, 0x2a / 255.0},
+	"pink":    {1, 0xc0 / 255.0, 0xcb / 255.0},
+}
+
+// parseSVGColor parses a CSS color value.
+func parseSVGColor(v string, current RGB) (RGB, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "currentcolor" {
+		return current, true
+	}
+	if c, ok := svgColorNames[v]; ok {
+		return c, true
+	}
+	if strings.HasPrefix(v, "#") {
+		hex := v[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return RGB{}, false
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return RGB{}, false
+		}
+		return RGB{float32(n>>16) / 255, float32(n>>8&0xff) / 255, float32(n&0xff) / 255}, true
+	}
+	if strings.HasPrefix(v, "rgb(") && strings.HasSuffix(v, ")") {
+		parts := strings.Split(v[4:len(v)-1], ",")
+		if len(parts) != 3 {
+			return RGB{}, false
+		}
+		var c [3]float32
+		for i, p := range parts {
+			c[i] = float32(math.Max(0, math.Min(1, svgLength(p, 1, 0)/255)))
+			if strings.HasSuffix(strings.TrimSpace(p), "%") {
+				c[i] = float32(math.Max(0, math.Min(1, svgLength(p, 1, 0))))
+			}
+		}
+		return RGB{c[0], c[1], c[2]}, true
+	}
+	return RGB{}, false
+}
+
+// svgFontWeight parses a font weight.  The relative weights bolder and
+// lighter are computed from the parent's weight as described in CSS Fonts
+// Level 4.
+func svgFontWeight(v string, parent int) int {
+	switch v = strings.TrimSpace(v); v {
+	case "normal":
+		return 400
+	case "bold":
+		return 700
+	case "bolder":
+		switch {
+		case parent < 350:
+			return 400
+		case parent < 550:
+			return 700
+		case parent < 900:
+			return 900
+		}
+		return parent
+	case "lighter":
+		switch {
+		case parent < 100:
+			return parent
+		case parent < 550:
+			return 100
+		case parent < 750:
+			return 400
+		}
+		return 700
+	}
+	if w, err := strconv.Atoi(v); err == nil && w >= 1 && w <= 1000 {
+		return w
+	}
+	return parent
+}
+
+func svgOpacity(v string) float64 {
+	return math.Max(0, math.Min(1, svgLength(v, 1, 0)))
+}
+
+// svgLength parses a length in user units.  Percentages are relative to ref
+// and em units to fontSize.
+func svgLength(v string, ref, fontSize float64) float64 {
+	v = strings.TrimSpace(v)
+	scale := 1.0
+	for _, u := range []struct {
+		suffix string
+		scale  float64
+	}{
+		{"%", ref / 100},
+		{"px", 1},
+		{"pt", 96.0 / 72.0},
+		{"pc", 16},
+		{"mm", 96 / 25.4},
+		{"cm", 96 / 2.54},
+		{"in", 96},
+		{"em", fontSize},
+		{"ex", fontSize / 2},
+	} {
+		if strings.HasSuffix(v, u.suffix) {
+			v, scale = strings.TrimSpace(v[:len(v)-len(u.suffix)]), u.scale
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	return f * scale
+}
+
+// svgNumbers parses a list of numbers separated by white space or commas.
+func svgNumbers(v string) []float64 {
+	p := &svgPathParser{s: v}
+	var f []float64
+	for p.hasNumber() {
+		n, err := p.number()
+		if err != nil {
+			break
+		}
+		f = append(f, n)
+	}
+	return f
+}
+
+// firstSVGValue returns the first entry of a list of lengths.
+func firstSVGValue(v string) string {
+	if f := strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+// parseSVGTransform parses the value of a transform attribute.  It returns
+// false if there is no transform.
+func parseSVGTransform(v string) (Matrix, bool) {
+	m := IdentityMatrix
+	found := false
+	for {
+		v = strings.TrimLeft(v, " \t\r\n,")
+		open := strings.Index(v, "(")
+		close := strings.Index(v, ")")
+		if open < 0 || close < open {
+			return m, found
+		}
+		fn := strings.TrimSpace(v[:open])
+		a := svgNumbers(v[open+1 : close])
+		v = v[close+1:]
+
+		t := IdentityMatrix
+		switch {
+		case fn == "matrix" && len(a) == 6:
+			t = Matrix{float32(a[0]), float32(a[1]), float32(a[2]), float32(a[3]), float32(a[4]), float32(a[5])}
+		case fn == "translate" && len(a) == 1:
+			t[4] = float32(a[0])
+		case fn == "translate" && len(a) == 2:
+			t[4], t[5] = float32(a[0]), float32(a[1])
+		case fn == "scale" && len(a) == 1:
+			t[0], t[3] = float32(a[0]), float32(a[0])
+		case fn == "scale" && len(a) == 2:
+			t[0], t[3] = float32(a[0]), float32(a[1])
+		case fn == "rotate" && (len(a) == 1 || len(a) == 3):
+			s, c := math.Sincos(a[0] * math.Pi / 180)
+			t = Matrix{float32(c), float32(s), float32(-s), float32(c), 0, 0}
+			if len(a) == 3 {
+				cx, cy := float32(a[1]), float32(a[2])
+				t = Matrix{1, 0, 0, 1, -cx, -cy}.Multiply(t).Multiply(Matrix{1, 0, 0, 1, cx, cy})
+			}
+		case fn == "skewX" && len(a) == 1:
+			t[2] = float32(math.Tan(a[0] * math.Pi / 180))
+		case fn == "skewY" && len(a) == 1:
+			t[1] = float32(math.Tan(a[0] * math.Pi / 180))
+		default:
+			continue
+		}
+		// Transforms listed later are applied first.
+		m = t.Multiply(m)
+		found = true
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testSVG = `<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"
+     width="200" height="100" viewBox="0 0 100 50">
+  <defs>
+    <linearGradient id="fade">
+      <stop offset="0" stop-color="#fff"/>
+      <stop offset="100%" stop-color="black"/>
+    </linearGradient>
+    <clipPath id="clip"><rect width="50" height="50"/></clipPath>
+  </defs>
+  <rect x="10" y="10" width="30" height="20" style="fill: rgb(255, 0, 0); stroke: blue"/>
+  <g transform="translate(5 5)" clip-path="url(#clip)">
+    <circle cx="10" cy="10" r="5" fill="url(#fade)" fill-opacity="0.5"/>
+    <path d="M0 0h10" stroke="#00ff00" stroke-width="2" fill="none"/>
+  </g>
+  <text x="50" y="40" font-family="serif" font-weight="bold" text-anchor="middle">Hi</text>
+</svg>
+`
+
+func TestParseSVG(t *testing.T) {
+	svg, err := ParseSVG(strings.NewReader(testSVG))
+	if err != nil {
+		t.Fatalf("ParseSVG error: %v", err)
+	}
+	if w, h := svg.Size(); w != 150 || h != 75 {
+		t.Errorf("svg.Size() = %v, %v; expected 150, 75", w, h)
+	}
+	if svg.ids["fade"] == nil || svg.ids["clip"] == nil {
+		t.Errorf("ids = %v", svg.ids)
+	}
+	if _, err := ParseSVG(strings.NewReader("<html></html>")); err == nil {
+		t.Error("ParseSVG of non-SVG document did not return an error")
+	}
+}
+
+func TestDrawSVG(t *testing.T) {
+	svg, err := ParseSVG(strings.NewReader(testSVG))
+	if err != nil {
+		t.Fatalf("ParseSVG error: %v", err)
+	}
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	svg.Draw(canvas)
+	canvas.Close()
+
+	output := canvasOutput(t, canvas)
+	for _, want := range []string{
+		// Flip and scale from the view box.
+		"1.00000 0.00000 0.00000 -1.00000 0.00000 75.00000 cm\n1.50000 0.00000 0.00000 1.50000 0.00000 0.00000 cm\n",
+		"1.00000 0.00000 0.00000 rg\n0.00000 0.00000 1.00000 RG\n",
+		"10.00000 10.00000 30.00000 20.00000 re\nB\n",
+		"0.00000 0.00000 50.00000 50.00000 re\nW n\n",
+		"/__gs0__ gs\n",
+		"W n\n10.00000 0.00000 0.00000 10.00000 5.00000 5.00000 cm\n/__shading0__ sh\n",
+		"2.00000 w\n",
+		"/Times-Bold 16.00000 Tf\n",
+		"(Hi) Tj\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output does not contain %q:\n%s", want, output)
+		}
+	}
+	// The initial miter limit is set once, not for every stroke.
+	if n := strings.Count(output, " M\n"); n != 1 {
+		t.Errorf("miter limit was set %d times, expected 1:\n%s", n, output)
+	}
+	if strings.Count(output, "q\n") != strings.Count(output, "Q\n") {
+		t.Errorf("Unbalanced q/Q in output:\n%s", output)
+	}
+}
+
+func TestDrawSVGCyclicUse(t *testing.T) {
+	svg, err := ParseSVG(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">
+  <g id="a"><rect width="1" height="1"/><use href="#a"/><use href="#b"/></g>
+  <use id="b" href="#a"/>
+</svg>`))
+	if err != nil {
+		t.Fatalf("ParseSVG error: %v", err)
+	}
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	svg.Draw(canvas)
+	canvas.Close()
+
+	// The group is drawn by itself, through its own use elements and
+	// through b, but a use element never expands a target it is inside.
+	output := canvasOutput(t, canvas)
+	if n := strings.Count(output, " re\n"); n != 4 {
+		t.Errorf("rectangle was drawn %d times, expected 4:\n%s", n, output)
+	}
+}
+
+func TestDrawSVGGradientStroke(t *testing.T) {
+	svg, err := ParseSVG(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100">
+  <linearGradient id="g"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+  <rect x="10" y="20" width="30" height="40" fill="none" stroke="url(#g)"/>
+  <line x1="0" y1="5" x2="50" y2="5" stroke="url(#g)"/>
+</svg>`))
+	if err != nil {
+		t.Fatalf("ParseSVG error: %v", err)
+	}
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	svg.Draw(canvas)
+	canvas.Close()
+
+	output := canvasOutput(t, canvas)
+	if want := "/Pattern CS\n/__pattern0__ SCN\n"; !strings.Contains(output, want) {
+		t.Errorf("Output does not contain %q:\n%s", want, output)
+	}
+	// The line has an empty bounding box, so its gradient is not painted.
+	if n := strings.Count(output, "\nS\n"); n != 1 {
+		t.Errorf("%d paths were stroked, expected 1:\n%s", n, output)
+	}
+
+	ref := canvas.resources.Pattern["__pattern0__"].(Reference)
+	pat := doc.objects[ref.Number-1].(shadingPattern)
+	// The bounding box, then the flip and scale from pixels to the page.
+	expected := Matrix{30, 0, 0, 40, 10, 20}.Multiply(Matrix{0.75, 0, 0, -0.75, 0, 75})
+	for i := range expected {
+		if d := pat.Matrix[i] - expected[i]; d < -1e-4 || d > 1e-4 {
+			t.Errorf("pattern matrix = %v, expected %v", pat.Matrix, expected)
+			break
+		}
+	}
+}
+
+type svgColorTest struct {
+	Value    string
+	Expected RGB
+	OK       bool
+}
+
+var svgColorTests = []svgColorTest{
+	{"#000", RGB{0, 0, 0}, true},
+	{"#ff8000", RGB{1, 128.0 / 255, 0}, true},
+	{"rgb(0, 255, 0)", RGB{0, 1, 0}, true},
+	{"rgb(100%, 0%, 50%)", RGB{1, 0, 0.5}, true},
+	{"Navy", RGB{0, 0, 128.0 / 255}, true},
+	{"#12", RGB{}, false},
+	{"bogus", RGB{}, false},
+}
+
+func TestParseSVGColor(t *testing.T) {
+	for _, tt := range svgColorTests {
+		c, ok := parseSVGColor(tt.Value, RGB{})
+		if ok != tt.OK || c != tt.Expected {
+			t.Errorf("parseSVGColor(%q) = %v, %t; expected %v, %t", tt.Value, c, ok, tt.Expected, tt.OK)
+		}
+	}
+}
+
+func TestSVGFontWeight(t *testing.T) {
+	tests := []struct {
+		Value    string
+		Parent   int
+		Expected int
+	}{
+		{"bold", 400, 700},
+		{"normal", 700, 400},
+		{" 600 ", 400, 600},
+		{"1000", 400, 1000},
+		{"1001", 400, 400},
+		{"bolder", 300, 400},
+		{"bolder", 400, 700},
+		{"bolder", 700, 900},
+		{"lighter", 700, 400},
+		{"lighter", 400, 100},
+		{"inherit", 700, 700},
+	}
+	for _, tt := range tests {
+		if w := svgFontWeight(tt.Value, tt.Parent); w != tt.Expected {
+			t.Errorf("svgFontWeight(%q, %d) = %d, expected %d", tt.Value, tt.Parent, w, tt.Expected)
+		}
+	}
+
+	st := defaultSVGStyle()
+	st.fontWeight = svgFontWeight("bolder", st.fontWeight)
+	if f := svgFont(st); f != HelveticaBold {
+		t.Errorf("svgFont = %s, expected %s", f, HelveticaBold)
+	}
+	st.fontWeight = svgFontWeight("bolder", 300)
+	if f := svgFont(st); f != Helvetica {
+		t.Errorf("svgFont = %s, expected %s", f, Helvetica)
+	}
+}
+
+func TestParseSVGTransform(t *testing.T) {
+	m, ok := parseSVGTransform("translate(10,20) rotate(90) scale(2)")
+	if !ok {
+		t.Fatal("parseSVGTransform found no transform")
+	}
+	// The point (1, 0) is scaled to (2, 0), rotated to (0, 2) and
+	// translated to (10, 22).
+	x := m[0]*1 + m[2]*0 + m[4]
+	y := m[1]*1 + m[3]*0 + m[5]
+	if math.Abs(float64(x-10)) > 1e-5 || math.Abs(float64(y-22)) > 1e-5 {
+		t.Errorf("transform maps (1, 0) to (%g, %g), expected (10, 22)", x, y)
+	}
+	if _, ok := parseSVGTransform(""); ok {
+		t.Error(`parseSVGTransform("") found a transform`)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ParseSVGPath converts SVG path data (the d attribute of an SVG path
+// element) into a path.  All SVG path commands are supported, in both their
+// absolute and relative forms; quadratic curves and elliptical arcs are
+// converted into cubic Bezier curves.
+//
+// The coordinates are used unchanged, so the y axis points up as usual in
+// PDF rather than down as in SVG.  To draw an SVG icon upright, flip the
+// canvas's coordinate system, e.g. with canvas.Transform(1, 0, 0, -1, 0, h).
+func ParseSVGPath(d string) (*Path, error) {
+	path := new(Path)
+	if err := path.appendSVG(d); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// svgPathParser holds the state needed to interpret SVG path data.
+type svgPathParser struct {
+	s string
+	i int
+
+	cur, start Point
+	// ctrl is the last control point of the previous command if it was a
+	// curve, used by the smooth curve commands S and T.
+	ctrl    Point
+	lastCmd byte
+}
+
+func (p *svgPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("pdf: invalid SVG path data at offset %d: %s", p.i, fmt.Sprintf(format, args...))
+}
+
+func isSVGSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// skipSeparators skips white space and at most one comma.
+func (p *svgPathParser) skipSeparators() {
+	for p.i < len(p.s) && isSVGSpace(p.s[p.i]) {
+		p.i++
+	}
+	if p.i < len(p.s) && p.s[p.i] == ',' {
+		p.i++
+		for p.i < len(p.s) && isSVGSpace(p.s[p.i]) {
+			p.i++
+		}
+	}
+}
+
+// hasNumber reports whether the next token is a number.
+func (p *svgPathParser) hasNumber() bool {
+	p.skipSeparators()
+	if p.i >= len(p.s) {
+		return false
+	}
+	c := p.s[p.i]
+	return c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9')
+}
+
+func (p *svgPathParser) number() (float64, error) {
+	if !p.hasNumber() {
+		return 0, p.errorf("expected number")
+	}
+	start := p.i
+	if c := p.s[p.i]; c == '+' || c == '-' {
+		p.i++
+	}
+	digits := func() int {
+		n := 0
+		for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+			n++
+		}
+		return n
+	}
+	n := digits()
+	if p.i < len(p.s) && p.s[p.i] == '.' {
+		p.i++
+		n += digits()
+	}
+	if n == 0 {
+		return 0, p.errorf("expected number")
+	}
+	if p.i < len(p.s) && (p.s[p.i] == 'e' || p.s[p.i] == 'E') {
+		mark := p.i
+		p.i++
+		if p.i < len(p.s) && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+			p.i++
+		}
+		if digits() == 0 {
+			// Not an exponent after all.
+			p.i = mark
+		}
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.i], 64)
+	if err != nil {
+		return 0, p.errorf("%v", err)
+	}
+	return f, nil
+}
+
+// flag parses an arc flag, which may be written without separators.
+func (p *svgPathParser) flag() (bool, error) {
+	p.skipSeparators()
+	if p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '0':
+			p.i++
+			return false, nil
+		case '1':
+			p.i++
+			return true, nil
+		}
+	}
+	return false, p.errorf("expected flag")
+}
+
+// point parses a coordinate pair, relative to the current point if rel is
+// true.
+func (p *svgPathParser) point(rel bool) (Point, error) {
+	x, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	y, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	pt := Point{Unit(x), Unit(y)}
+	if rel {
+		pt.X += p.cur.X
+		pt.Y += p.cur.Y
+	}
+	return pt, nil
+}
+
+// reflectedCtrl returns the reflection of the previous control point about
+// the current point if the previous command was one of cmds, or the current
+// point otherwise.
+func (p *svgPathParser) reflectedCtrl(cmds string) Point {
+	for i := 0; i < len(cmds); i++ {
+		if p.lastCmd == cmds[i] {
+			return Point{2*p.cur.X - p.ctrl.X, 2*p.cur.Y - p.ctrl.Y}
+		}
+	}
+	return p.cur
+}
+
+// appendSVG appends the subpaths described by SVG path data to the path.
+func (path *Path) appendSVG(d string) error {
+	p := &svgPathParser{s: d}
+	var cmd byte
+	for {
+		p.skipSeparators()
+		if p.i >= len(p.s) {
+			return nil
+		}
+		if c := p.s[p.i]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			cmd = c
+			p.i++
+		} else if cmd == 0 || cmd == 'z' || cmd == 'Z' {
+			return p.errorf("expected command")
+		}
+		if cmd != 'M' && cmd != 'm' && p.lastCmd == 0 {
+			return p.errorf("path data must begin with a moveto")
+		}
+		if err := path.svgCommand(p, cmd); err != nil {
+			return err
+		}
+		// Coordinates following a moveto are implicit lineto commands.
+		switch cmd {
+		case 'M':
+			cmd = 'L'
+		case 'm':
+			cmd = 'l'
+		}
+	}
+}
+
+func (path *Path) svgCommand(p *svgPathParser, cmd byte) error {
+	rel := cmd >= 'a'
+	var ctrl Point
+	switch cmd {
+	case 'M', 'm':
+		pt, err := p.point(rel)
+		if err != nil {
+			return err
+		}
+		path.Move(pt)
+		p.cur, p.start = pt, pt
+	case 'L', 'l':
+		pt, err := p.point(rel)
+		if err != nil {
+			return err
+		}
+		path.Line(pt)
+		p.cur = pt
+	case 'H', 'h', 'V', 'v':
+		v, err := p.number()
+		if err != nil {
+			return err
+		}
+		pt := p.cur
+		switch cmd {
+		case 'H':
+			pt.X = Unit(v)
+		case 'h':
+			pt.X += Unit(v)
+		case 'V':
+			pt.Y = Unit(v)
+		case 'v':
+			pt.Y += Unit(v)
+		}
+		path.Line(pt)
+		p.cur = pt
+	case 'C', 'c', 'S', 's':
+		var pts [3]Point
+		i := 0
+		if cmd == 'S' || cmd == 's' {
+			pts[0] = p.reflectedCtrl("CcSs")
+			i = 1
+		}
+		for ; i < 3; i++ {
+			pt, err := p.point(rel)
+			if err != nil {
+				return err
+			}
+			pts[i] = pt
+		}
+		path.Curve(pts[0], pts[1], pts[2])
+		ctrl, p.cur = pts[1], pts[2]
+	case 'Q', 'q', 'T', 't':
+		var q Point
+		if cmd == 'T' || cmd == 't' {
+			q = p.reflectedCtrl("QqTt")
+		} else {
+			pt, err := p.point(rel)
+			if err != nil {
+				return err
+			}
+			q = pt
+		}
+		end, err := p.point(rel)
+		if err != nil {
+			return err
+		}
+		path.QuadCurve(q, end)
+		ctrl, p.cur = q, end
+	case 'A', 'a':
+		var v [3]float64
+		for i := range v {
+			f, err := p.number()
+			if err != nil {
+				return err
+			}
+			v[i] = f
+		}
+		large, err := p.flag()
+		if err != nil {
+			return err
+		}
+		sweep, err := p.flag()
+		if err != nil {
+			return err
+		}
+		end, err := p.point(rel)
+		if err != nil {
+			return err
+		}
+		path.svgArc(p.cur, end, v[0], v[1], v[2]*math.Pi/180, large, sweep)
+		p.cur = end
+	case 'Z', 'z':
+		path.Close()
+		p.cur = p.start
+	default:
+		return p.errorf("unknown command %q", cmd)
+	}
+	p.ctrl = ctrl
+	p.lastCmd = cmd
+	return nil
+}
+
+// svgArc appends an elliptical arc given in SVG's endpoint parameterization.
+// The computation follows Section F.6.5 of the SVG 1.1 specification.
+func (path *Path) svgArc(from, to Point, rx, ry, phi float64, large, sweep bool) {
+	if from == to {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		path.Line(to)
+		return
+	}
+
+	x1, y1 := float64(from.X), float64(from.Y)
+	x2, y2 := float64(to.X), float64(to.Y)
+	sinPhi, cosPhi := math.Sincos(phi)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p := cosPhi*dx + sinPhi*dy
+	y1p := -sinPhi*dx + cosPhi*dy
+
+	// Scale up radii that are too small to span the endpoints.
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := -coef * ry * x1p / rx
+	cx := cosPhi*cxp - sinPhi*cyp + (x1+x2)/2
+	cy := sinPhi*cxp + cosPhi*cyp + (y1+y2)/2
+
+	theta := math.Atan2((y1p-cyp)/ry, (x1p-cxp)/rx)
+	dtheta := math.Atan2((-y1p-cyp)/ry, (-x1p-cxp)/rx) - theta
+	if sweep && dtheta < 0 {
+		dtheta += 2 * math.Pi
+	} else if !sweep && dtheta > 0 {
+		dtheta -= 2 * math.Pi
+	}
+	path.ellipticalArc(Point{Unit(cx), Unit(cy)}, Unit(rx), Unit(ry), phi, theta, dtheta)
+}
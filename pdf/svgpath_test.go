@@ -0,0 +1,85 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"math"
+	"testing"
+)
+
+type svgPathTest struct {
+	Data     string
+	Expected string
+}
+
+var svgPathTests = []svgPathTest{
+	{"M10 20L30 40", "10.00000 20.00000 m\n30.00000 40.00000 l\n"},
+	{"m10,20 30,40z", "10.00000 20.00000 m\n40.00000 60.00000 l\nh\n"},
+	{"M0 0H10V-5h-3v1", "0.00000 0.00000 m\n10.00000 0.00000 l\n10.00000 -5.00000 l\n7.00000 -5.00000 l\n7.00000 -4.00000 l\n"},
+	{"M0,0C1,2,3,4,5,6S9,10,11,12", "0.00000 0.00000 m\n1.00000 2.00000 3.00000 4.00000 5.00000 6.00000 c\n7.00000 8.00000 9.00000 10.00000 11.00000 12.00000 c\n"},
+	{"M0 0s1 2 3 4", "0.00000 0.00000 m\n0.00000 0.00000 1.00000 2.00000 3.00000 4.00000 c\n"},
+	{"M0 0Q30 60 90 30", "0.00000 0.00000 m\n20.00000 40.00000 50.00000 50.00000 90.00000 30.00000 c\n"},
+	{"M0 0q30 60 90 30t90 0", "0.00000 0.00000 m\n20.00000 40.00000 50.00000 50.00000 90.00000 30.00000 c\n" +
+		"130.00000 10.00000 160.00000 10.00000 180.00000 30.00000 c\n"},
+	{"M.5.5-1e1-.5", "0.50000 0.50000 m\n-10.00000 -0.50000 l\n"},
+	{"M0 0A0 5 0 0 1 10 10", "0.00000 0.00000 m\n10.00000 10.00000 l\n"},
+	{"M0 0 Z M1 1 z", "0.00000 0.00000 m\nh\n1.00000 1.00000 m\nh\n"},
+}
+
+func TestParseSVGPath(t *testing.T) {
+	for i, tt := range svgPathTests {
+		path, err := ParseSVGPath(tt.Data)
+		if err != nil {
+			t.Errorf("%d. ParseSVGPath(%q) error: %v", i, tt.Data, err)
+			continue
+		}
+		if pathString(path) != tt.Expected {
+			t.Errorf("%d. ParseSVGPath(%q) = %q, expected %q", i, tt.Data, pathString(path), tt.Expected)
+		}
+	}
+}
+
+var badSVGPaths = []string{
+	"L10 10",
+	"M10",
+	"M10 10 L",
+	"M0 0 X 1 1",
+	"M0 0 A 1 1 0 2 0 5 5",
+	"M0 0 z 10 10",
+}
+
+func TestParseSVGPathErrors(t *testing.T) {
+	for _, d := range badSVGPaths {
+		if _, err := ParseSVGPath(d); err == nil {
+			t.Errorf("ParseSVGPath(%q) did not return an error", d)
+		}
+	}
+}
+
+func TestParseSVGArc(t *testing.T) {
+	// Half of a circle with radius 10 centered at (10, 0), with compact flags.
+	path, err := ParseSVGPath("M0 0a10 10 0 01 20 0")
+	if err != nil {
+		t.Fatalf("ParseSVGPath error: %v", err)
+	}
+	curves := pathCurves(t, path)
+	if len(curves) != 2 {
+		t.Fatalf("half circle has %d curves, expected 2", len(curves))
+	}
+	for i, c := range curves {
+		for j := 0; j <= 8; j++ {
+			x, y := bezierPoint(c, float64(j)/8)
+			if d := math.Hypot(x-10, y) - 10; math.Abs(d) > 0.01 {
+				t.Errorf("curve %d at t=%d/8 deviates from circle by %g", i, j, d)
+			}
+		}
+	}
+	if end := curves[1][3]; math.Abs(float64(end.X-20)) > 1e-4 || math.Abs(float64(end.Y)) > 1e-4 {
+		t.Errorf("arc ends at %v, expected {20 0}", end)
+	}
+	// The sweep flag selects the direction of increasing angles, which is
+	// clockwise on screen in SVG's y-down coordinate system.
+	if _, y := bezierPoint(curves[0], 1); y > -9.99 {
+		t.Errorf("arc midpoint has y = %g, expected -10", y)
+	}
+}
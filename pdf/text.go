@@ -4,6 +4,7 @@ package pdf
 
 import (
 	"bytes"
+	"errors"
 )
 
 // Text is a PDF text object.  The zero value is an empty text object.
@@ -15,8 +16,24 @@ type Text struct {
 	currFont    *Font
 	currSize    Unit
 	currLeading Unit
+	hasLeading  bool
+
+	// prefix is the length of the text state operators at the start of
+	// buf.  Their combined effect is to select startFont at startSize and
+	// set startLeading; DrawText writes them only where the canvas's text
+	// state differs.
+	prefix          int
+	startFont       *Font
+	startSize       Unit
+	startLeading    Unit
+	startHasLeading bool
+
+	// err is set if the text object cannot be drawn.
+	err error
 }
 
+var errNoFont = errors.New("pdf: text shown before a font was set")
+
 // Width computes the width of a string in the given font and font size.
 func (font *Font) Width(s string, fontSize Unit) Unit {
 	width := Unit(0)
@@ -41,6 +58,12 @@ func (font *Font) CodePoints(s string) []byte {
 
 // Text adds a string to the text object.
 func (text *Text) Text(s string) {
+	if text.currFont == nil {
+		if text.err == nil {
+			text.err = errNoFont
+		}
+		return
+	}
 	text.x += text.currFont.Width(s, text.currSize)
 	encoded := text.currFont.CodePoints(s)
 	writeCommand(&text.buf, "Tj", string(encoded))
@@ -56,9 +79,13 @@ func (text *Text) UseFont(font *Font, size Unit, leading Unit) {
 	} else {
 		text.fonts[font.pdfName] = font
 	}
+	if text.currFont == nil || text.currFont.pdfName != font.pdfName || text.currSize != size {
+		if text.writeState("Tf", font.pdfName, size) {
+			text.startFont, text.startSize = font, size
+		}
+	}
 	text.currFont = font
 	text.currSize = size
-	writeCommand(&text.buf, "Tf", font.pdfName, size)
 	text.SetLeading(leading)
 }
 
@@ -89,8 +116,25 @@ func (text *Text) SetFont(fontName string, size Unit) {
 
 // SetLeading changes the amount of space between lines.
 func (text *Text) SetLeading(leading Unit) {
-	writeCommand(&text.buf, "TL", leading)
-	text.currLeading = leading
+	if text.hasLeading && text.currLeading == leading {
+		return
+	}
+	if text.writeState("TL", leading) {
+		text.startLeading, text.startHasLeading = leading, true
+	}
+	text.currLeading, text.hasLeading = leading, true
+}
+
+// writeState writes a text state operator.  It reports whether the operator
+// is part of the prefix of buf, that is, nothing but text state operators has
+// been written before it.
+func (text *Text) writeState(op string, args ...interface{}) bool {
+	inPrefix := text.prefix == text.buf.Len()
+	writeCommand(&text.buf, op, args...)
+	if inPrefix {
+		text.prefix = text.buf.Len()
+	}
+	return inPrefix
 }
 
 // NextLine advances the current text position to the next line, based on the
@@ -42,7 +42,6 @@ const textExpectedEncodedOutput = `/Helvetica,WinAnsiEncoding 10.00000 Tf
 T*
 <E5E4F6> Tj
 /ZapfDingbats 10.00000 Tf
-12.00000 TL
 <A4> Tj
 `
 
@@ -130,3 +129,26 @@ func TestTextY(t *testing.T) {
 		t.Errorf("NextLineOffset does not set Y correctly, y = %.5f (expected %.5f)", text.Y(), -50.130)
 	}
 }
+
+const textRepeatedFontExpectedOutput = `/Helvetica 12.00000 Tf
+14.40000 TL
+(a) Tj
+(b) Tj
+/Helvetica 10.00000 Tf
+12.00000 TL
+(c) Tj
+`
+
+func TestTextRepeatedFont(t *testing.T) {
+	text := new(Text)
+	text.SetFont(Helvetica, 12)
+	text.Text("a")
+	text.SetFont(Helvetica, 12)
+	text.Text("b")
+	text.SetFont(Helvetica, 10)
+	text.Text("c")
+
+	if text.buf.String() != textRepeatedFontExpectedOutput {
+		t.Errorf("Output was %q, expected %q", text.buf.String(), textRepeatedFontExpectedOutput)
+	}
+}
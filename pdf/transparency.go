@@ -0,0 +1,165 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+// BlendMode is a PDF blend mode, used to composite objects with the backdrop.
+// See Section 11.3.5 of ISO 32000-1.
+type BlendMode string
+
+// Standard blend modes
+const (
+	BlendNormal     BlendMode = "Normal"
+	BlendMultiply   BlendMode = "Multiply"
+	BlendScreen     BlendMode = "Screen"
+	BlendOverlay    BlendMode = "Overlay"
+	BlendDarken     BlendMode = "Darken"
+	BlendLighten    BlendMode = "Lighten"
+	BlendColorDodge BlendMode = "ColorDodge"
+	BlendColorBurn  BlendMode = "ColorBurn"
+	BlendHardLight  BlendMode = "HardLight"
+	BlendSoftLight  BlendMode = "SoftLight"
+	BlendDifference BlendMode = "Difference"
+	BlendExclusion  BlendMode = "Exclusion"
+	BlendHue        BlendMode = "Hue"
+	BlendSaturation BlendMode = "Saturation"
+	BlendColor      BlendMode = "Color"
+	BlendLuminosity BlendMode = "Luminosity"
+)
+
+// SoftMaskType selects which property of a transparency group is used as
+// the mask values of a soft mask.
+type SoftMaskType string
+
+// Soft mask types
+const (
+	// LuminositySoftMask uses the luminosity of the group's colors: white
+	// is fully opaque, black is fully transparent.
+	LuminositySoftMask SoftMaskType = "Luminosity"
+
+	// AlphaSoftMask uses the group's opacity.
+	AlphaSoftMask SoftMaskType = "Alpha"
+)
+
+const (
+	transparencyGroupSubtype name = "Transparency"
+	noSoftMask               name = "None"
+)
+
+// transparencyGroup is a group attributes dictionary.
+type transparencyGroup struct {
+	Type name
+	S    name
+	CS   name `pdf:",omitempty"`
+	I    bool `pdf:",omitempty"`
+	K    bool `pdf:",omitempty"`
+}
+
+// softMask is a soft-mask dictionary.
+type softMask struct {
+	Type name
+	S    name
+	G    Reference
+}
+
+// extGState is a graphics state parameter dictionary.  Nil fields are left
+// unchanged when the dictionary is applied with the gs operator.
+type extGState struct {
+	Type        name
+	StrokeAlpha *float32    `pdf:"CA,omitempty"`
+	FillAlpha   *float32    `pdf:"ca,omitempty"`
+	BM          name        `pdf:",omitempty"`
+	SMask       interface{} `pdf:",omitempty"`
+}
+
+// addExtGState adds a graphics state parameter dictionary to the document.
+// Identical dictionaries are only stored once.
+func (doc *Document) addExtGState(gs extGState) Reference {
+	gs.Type = extGStateType
+	data, err := marshal(nil, gs)
+	if err != nil {
+		// All fields of extGState are marshalable.
+		panic(err)
+	}
+	key := string(data)
+	if ref, ok := doc.extGStates[key]; ok {
+		return ref
+	}
+	ref := doc.add(gs)
+	doc.extGStates[key] = ref
+	return ref
+}
+
+const anonymousExtGStateFormat = "__gs%d__"
+
+// setExtGState applies a graphics state parameter dictionary to the canvas.
+func (canvas *Canvas) setExtGState(gs extGState) {
+	ref := canvas.doc.addExtGState(gs)
+	n := resourceName(&canvas.resources.ExtGState, anonymousExtGStateFormat, ref)
+	canvas.command("gs", n)
+}
+
+// SetAlpha changes the constant opacity used for filling and stroking.  The
+// values range from 0 (fully transparent) to 1 (fully opaque).
+func (canvas *Canvas) SetAlpha(fill, stroke float32) {
+	canvas.setExtGState(extGState{
+		FillAlpha:   &fill,
+		StrokeAlpha: &stroke,
+	})
+}
+
+// SetBlendMode changes the blend mode used to composite subsequent drawing
+// operations with the page.
+func (canvas *Canvas) SetBlendMode(mode BlendMode) {
+	canvas.setExtGState(extGState{
+		BM: name(mode),
+	})
+}
+
+// NewGroup creates a transparency group with the given bounding box and
+// returns a canvas for drawing its contents.  The objects in a group are
+// composited with each other before the result is composited with the page,
+// so constant opacity and blend modes apply to the group as a whole.  An
+// isolated group is composited onto a transparent backdrop instead of the
+// page, and in a knockout group each object replaces, rather than composites
+// with, earlier objects in the group.
+//
+// Close must be called on the returned canvas once drawing has completed.
+// The group can then be painted with DrawGroup or used as a soft mask with
+// SetSoftMask by passing the canvas's Reference.
+func (doc *Document) NewGroup(bbox Rectangle, isolated, knockout bool) *Canvas {
+	return doc.newForm(bbox, &transparencyGroup{
+		Type: groupType,
+		S:    transparencyGroupSubtype,
+		CS:   deviceRGBColorSpace,
+		I:    isolated,
+		K:    knockout,
+	})
+}
+
+// DrawGroup paints a transparency group created with NewGroup.  The group's
+// coordinates are interpreted in the canvas's current coordinate system.
+func (canvas *Canvas) DrawGroup(group Reference) {
+	name := resourceName(&canvas.resources.XObject, anonymousXObjectFormat, group)
+	canvas.command("Do", name)
+}
+
+// SetSoftMask masks subsequent drawing operations with the transparency
+// group created with NewGroup.  The mask's coordinates are interpreted in the
+// canvas's current coordinate system.  Areas outside the group's bounding box
+// are masked out completely.
+func (canvas *Canvas) SetSoftMask(group Reference, typ SoftMaskType) {
+	canvas.setExtGState(extGState{
+		SMask: softMask{
+			Type: maskType,
+			S:    name(typ),
+			G:    group,
+		},
+	})
+}
+
+// ClearSoftMask removes the soft mask set by SetSoftMask.
+func (canvas *Canvas) ClearSoftMask() {
+	canvas.setExtGState(extGState{
+		SMask: noSoftMask,
+	})
+}
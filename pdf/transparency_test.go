@@ -0,0 +1,122 @@
+// Copyright (C) 2011, Ross Light
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+// canvasOutput returns the uncompressed content stream of a closed canvas.
+func canvasOutput(t *testing.T, canvas *Canvas) string {
+	r, err := zlib.NewReader(bytes.NewReader(canvas.contents.Bytes()))
+	if err != nil {
+		t.Fatalf("zlib.NewReader: %v", err)
+	}
+	output, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading content stream: %v", err)
+	}
+	return string(output)
+}
+
+const alphaExpectedOutput = `/__gs0__ gs
+/__gs1__ gs
+/__gs0__ gs
+`
+
+func TestSetAlpha(t *testing.T) {
+	doc := New()
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetAlpha(0.5, 1)
+	canvas.SetBlendMode(BlendMultiply)
+	canvas.SetAlpha(0.5, 1)
+	canvas.Close()
+
+	if output := canvasOutput(t, canvas); output != alphaExpectedOutput {
+		t.Errorf("Output was %q, expected %q", output, alphaExpectedOutput)
+	}
+	if n := len(canvas.page.Resources.ExtGState); n != 2 {
+		t.Errorf("Page has %d ExtGState resources, expected 2", n)
+	}
+	if n := len(doc.extGStates); n != 2 {
+		t.Errorf("Document has %d ExtGState dictionaries, expected 2", n)
+	}
+}
+
+func TestExtGStateShared(t *testing.T) {
+	doc := New()
+	c1 := doc.NewPage(USLetterWidth, USLetterHeight)
+	c1.SetAlpha(0, 0.25)
+	c1.Close()
+	c2 := doc.NewPage(USLetterWidth, USLetterHeight)
+	c2.SetAlpha(0, 0.25)
+	c2.Close()
+
+	r1 := c1.page.Resources.ExtGState["__gs0__"]
+	r2 := c2.page.Resources.ExtGState["__gs0__"]
+	if r1 == nil || r1 != r2 {
+		t.Errorf("ExtGState references differ: %v and %v", r1, r2)
+	}
+
+	const expected = `<< /Type /ExtGState /CA 0.25000 /ca 0.00000 >>`
+	b, err := marshal(nil, doc.objects[r1.(Reference).Number-1])
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if string(b) != expected {
+		t.Errorf("ExtGState is %q, expected %q", b, expected)
+	}
+}
+
+func TestSoftMask(t *testing.T) {
+	doc := New()
+	mask := doc.NewGroup(Rectangle{Point{0, 0}, Point{100, 100}}, false, false)
+	mask.SetColor(1, 1, 1)
+	mask.Close()
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.SetSoftMask(mask.Reference(), LuminositySoftMask)
+	canvas.ClearSoftMask()
+	canvas.Close()
+
+	gs := doc.objects[canvas.resources.ExtGState["__gs0__"].(Reference).Number-1]
+	expected := fmt.Sprintf(`<< /Type /ExtGState /SMask << /Type /Mask /S /Luminosity /G %d 0 R >> >>`, mask.Reference().Number)
+	if b, _ := marshal(nil, gs); string(b) != expected {
+		t.Errorf("soft mask ExtGState is %q, expected %q", b, expected)
+	}
+	gs = doc.objects[canvas.resources.ExtGState["__gs1__"].(Reference).Number-1]
+	expected = `<< /Type /ExtGState /SMask /None >>`
+	if b, _ := marshal(nil, gs); string(b) != expected {
+		t.Errorf("cleared soft mask ExtGState is %q, expected %q", b, expected)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	doc := New()
+	group := doc.NewGroup(Rectangle{Point{0, 0}, Point{10, 20}}, true, false)
+	if w, h := group.Size(); w != 10 || h != 20 {
+		t.Errorf("group.Size() = %v, %v; want 10, 20", w, h)
+	}
+	group.SetAlpha(0.5, 0.5)
+	group.Close()
+
+	canvas := doc.NewPage(USLetterWidth, USLetterHeight)
+	canvas.DrawGroup(group.Reference())
+	canvas.Close()
+
+	if output, expected := canvasOutput(t, canvas), "/__xobject0__ Do\n"; output != expected {
+		t.Errorf("Output was %q, expected %q", output, expected)
+	}
+	form := doc.objects[group.Reference().Number-1].(*formXObject)
+	expected := `<< /Type /Group /S /Transparency /CS /DeviceRGB /I true >>`
+	if b, _ := marshal(nil, form.Group); string(b) != expected {
+		t.Errorf("group dictionary is %q, expected %q", b, expected)
+	}
+	if len(form.Resources.ExtGState) != 1 {
+		t.Errorf("group has %d ExtGState resources, expected 1", len(form.Resources.ExtGState))
+	}
+}